@@ -0,0 +1,60 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"go.iscode.ca/mdg/pkg/config"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `%s %s
+Usage: %s config [<path>]
+
+Display the configuration of a file or directory: the %s files of the
+directory and its parents and the %s* environment variables.
+
+`, path.Base(os.Args[0]), config.Version(), os.Args[0], config.FileName, config.EnvPrefix)
+	fmt.Fprintf(os.Stderr, "Options:\n\n")
+	flag.PrintDefaults()
+}
+
+func Run() {
+	flag.Usage = func() { usage() }
+
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	if st, err := os.Stat(dir); err == nil && !st.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	base, err := config.Load(".")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// The options ignored by the commands are not displayed.
+	for _, v := range cfg.Nested(base, "", nil) {
+		fmt.Fprintln(os.Stderr, "warning:", v)
+	}
+
+	if _, err := cfg.WriteTo(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,196 @@
+package convert
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheFile is the default name of the build cache, written to the
+// output directory or to the working directory.
+const CacheFile = ".mdg-cache.json"
+
+// cache records the digest of the inputs of each HTML file: the
+// markdown source, the converter options and the files read by the
+// template.
+type cache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]entry
+	dirty   bool
+}
+
+// entry is the digest of the markdown source and the converter options
+// and the digests of the files read by the template.
+type entry struct {
+	Digest string            `json:"digest"`
+	Files  map[string]string `json:"files,omitempty"`
+}
+
+// loadCache reads the cache file. A missing or invalid cache file is an
+// empty cache: all files are converted.
+func loadCache(path string) (*cache, error) {
+	c := &cache{
+		path:    path,
+		entries: make(map[string]entry),
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return c, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &c.entries); err != nil {
+		c.entries = make(map[string]entry)
+	}
+
+	return c, nil
+}
+
+// key returns the cache key of a file: its path relative to the
+// directory of the cache file.
+func (c *cache) key(file string) string {
+	dir, err := filepath.Abs(filepath.Dir(c.path))
+	if err != nil {
+		return file
+	}
+
+	p, err := filepath.Abs(file)
+	if err != nil {
+		return file
+	}
+
+	if rel, err := filepath.Rel(dir, p); err == nil {
+		return filepath.ToSlash(rel)
+	}
+
+	return p
+}
+
+// file returns the path of the file of a cache key.
+func (c *cache) file(key string) string {
+	p := filepath.FromSlash(key)
+	if filepath.IsAbs(p) {
+		return p
+	}
+
+	return filepath.Join(filepath.Dir(c.path), p)
+}
+
+// fresh reports whether the HTML file exists and was generated from
+// inputs with the digest and from the current content of the files read
+// by the template.
+func (c *cache) fresh(html, digest string) bool {
+	c.mu.Lock()
+	e, ok := c.entries[c.key(html)]
+	c.mu.Unlock()
+
+	if !ok || e.Digest != digest {
+		return false
+	}
+
+	for k, v := range e.Files {
+		if d, err := fileDigest(c.file(k)); err != nil || d != v {
+			return false
+		}
+	}
+
+	_, err := os.Stat(html)
+
+	return err == nil
+}
+
+// set records the digest of the inputs of the HTML file and the digests
+// of the files read by the template.
+func (c *cache) set(html, digest string, files []string) error {
+	e := entry{
+		Digest: digest,
+	}
+
+	for _, file := range files {
+		d, err := fileDigest(file)
+		if err != nil {
+			return err
+		}
+
+		if e.Files == nil {
+			e.Files = make(map[string]string)
+		}
+
+		e.Files[c.key(file)] = d
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[c.key(html)] = e
+	c.dirty = true
+
+	return nil
+}
+
+// save writes the cache file if a digest changed.
+func (c *cache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+
+	if err := os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, c.path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	c.dirty = false
+
+	return nil
+}
+
+// digest returns the digest of the markdown source and the digest of
+// the converter options.
+func digest(source []byte, options string) string {
+	h := sha256.New()
+
+	_, _ = h.Write([]byte(options))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write(source)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fileDigest returns the digest of the content of a file.
+func fileDigest(file string) (string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
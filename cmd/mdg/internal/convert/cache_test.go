@@ -0,0 +1,294 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.iscode.ca/mdg/internal/pkg/report"
+	"go.iscode.ca/mdg/internal/pkg/walk"
+	"go.iscode.ca/mdg/pkg/config"
+	"go.iscode.ca/mdg/pkg/markdown"
+)
+
+func writeFiles(t *testing.T, files map[string]string) bool {
+	for name, content := range files {
+		file := filepath.FromSlash(name)
+
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Errorf("%v", err)
+			return false
+		}
+
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Errorf("%v", err)
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	if !writeFiles(t, map[string]string{
+		"a.html":     "<p>a</p>",
+		"notice.txt": "notice",
+	}) {
+		return
+	}
+
+	c, err := loadCache(CacheFile)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if c.fresh("a.html", "d") {
+		t.Errorf("expected a missing cache file to be empty")
+	}
+
+	if err := c.set("a.html", "d", []string{"notice.txt"}); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := c.save(); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	c, err = loadCache(CacheFile)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	for _, v := range []struct {
+		html     string
+		digest   string
+		expected bool
+	}{
+		{"a.html", "d", true},
+		{"a.html", "e", false},
+		{"b.html", "d", false},
+	} {
+		if got := c.fresh(v.html, v.digest); got != v.expected {
+			t.Errorf("%s %s: expected %v, got %v", v.html, v.digest, v.expected, got)
+		}
+	}
+
+	// The HTML file is generated again once removed.
+	if err := os.Remove("a.html"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if c.fresh("a.html", "d") {
+		t.Errorf("expected a removed HTML file not to be fresh")
+	}
+}
+
+func TestCacheCorrupt(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	if !writeFiles(t, map[string]string{
+		"a.html":  "<p>a</p>",
+		CacheFile: `{"a.html": {"digest": `,
+	}) {
+		return
+	}
+
+	c, err := loadCache(CacheFile)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if len(c.entries) != 0 {
+		t.Errorf("expected an empty cache, got %v", c.entries)
+	}
+
+	if err := c.set("a.html", "d", nil); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := c.save(); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// The corrupt cache file is replaced.
+	c, err = loadCache(CacheFile)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if !c.fresh("a.html", "d") {
+		t.Errorf("expected the cache file to be written again")
+	}
+}
+
+// convertAll converts the markdown files of the working directory with
+// the hash check, as a new mdg run, and returns the status of each file.
+func convertAll(t *testing.T, args ...string) map[string]string {
+	cfg, err := config.Load(".")
+	if err != nil {
+		t.Errorf("%v", err)
+		return nil
+	}
+
+	c, err := loadCache(CacheFile)
+	if err != nil {
+		t.Errorf("%v", err)
+		return nil
+	}
+
+	o := &Opt{
+		walker:  walk.New(),
+		mds:     make(map[string]*markdown.Opt),
+		namings: make(map[string]markdown.Naming),
+		args:    append([]string{"-check", "hash"}, args...),
+		cfg:     cfg,
+		warned:  make(map[string]bool),
+		check:   "hash",
+		naming:  markdown.NamingExt,
+		roots:   roots([]string{"."}),
+		jobs:    1,
+		digests: make(map[string]string),
+		cache:   c,
+		report:  report.New(command, report.Converted, report.Skipped, report.Failed),
+	}
+
+	if err := o.run("."); err != nil {
+		t.Errorf("%v", err)
+		return nil
+	}
+
+	o.save()
+
+	statuses := make(map[string]string)
+
+	for _, v := range o.report.Results() {
+		statuses[v.File] = v.Status
+	}
+
+	return statuses
+}
+
+func TestCacheConvert(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	if !writeFiles(t, map[string]string{
+		"a.md":       "# A\n",
+		"b.md":       "# B\n",
+		"sub/c.md":   "# C\n",
+		"style.css":  "body { color: black; }",
+		"index.html": "<html><head><style>{{.DefaultCSS}}</style></head><body>{{.Body}}</body></html>",
+	}) {
+		return
+	}
+
+	args := []string{"-css", "style.css", "-template", "index.html"}
+
+	for _, v := range []struct {
+		name     string
+		files    map[string]string
+		expected map[string]string
+	}{
+		{
+			"first run",
+			nil,
+			map[string]string{"a.md": report.Converted, "b.md": report.Converted, "sub/c.md": report.Converted},
+		},
+		{
+			"unmodified",
+			nil,
+			map[string]string{"a.md": report.Skipped, "b.md": report.Skipped, "sub/c.md": report.Skipped},
+		},
+		{
+			"source",
+			map[string]string{"a.md": "# A modified\n"},
+			map[string]string{"a.md": report.Converted, "b.md": report.Skipped, "sub/c.md": report.Skipped},
+		},
+		{
+			"css",
+			map[string]string{"style.css": "body { color: red; }"},
+			map[string]string{"a.md": report.Converted, "b.md": report.Converted, "sub/c.md": report.Converted},
+		},
+		{
+			"template",
+			map[string]string{"index.html": "<html><body>{{.Body}}</body></html>"},
+			map[string]string{"a.md": report.Converted, "b.md": report.Converted, "sub/c.md": report.Converted},
+		},
+		{
+			"directory configuration",
+			map[string]string{"sub/.mdg.yaml": "convert:\n  typographer: true\n"},
+			map[string]string{"a.md": report.Skipped, "b.md": report.Skipped, "sub/c.md": report.Converted},
+		},
+	} {
+		if !writeFiles(t, v.files) {
+			return
+		}
+
+		statuses := convertAll(t, args...)
+
+		for file, expected := range v.expected {
+			if got := statuses[filepath.FromSlash(file)]; got != expected {
+				t.Errorf("%s: %s: expected %s, got %s", v.name, file, expected, got)
+			}
+		}
+	}
+}
+
+func TestCacheReadFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	if !writeFiles(t, map[string]string{
+		"a.md":       "# A\n",
+		"b.md":       "# B\n",
+		"notice.txt": "notice",
+		"index.html": `<html><body>{{.Body}}{{if eq .Path "a.md"}}{{readFile "notice.txt"}}{{end}}</body></html>`,
+	}) {
+		return
+	}
+
+	args := []string{"-template", "index.html"}
+
+	for _, v := range []struct {
+		name     string
+		files    map[string]string
+		expected map[string]string
+	}{
+		{"first run", nil, map[string]string{"a.md": report.Converted, "b.md": report.Converted}},
+		{"unmodified", nil, map[string]string{"a.md": report.Skipped, "b.md": report.Skipped}},
+		{"read file", map[string]string{"notice.txt": "modified"}, map[string]string{"a.md": report.Converted, "b.md": report.Skipped}},
+		{"converted again", nil, map[string]string{"a.md": report.Skipped, "b.md": report.Skipped}},
+	} {
+		if !writeFiles(t, v.files) {
+			return
+		}
+
+		statuses := convertAll(t, args...)
+
+		for file, expected := range v.expected {
+			if got := statuses[file]; got != expected {
+				t.Errorf("%s: %s: expected %s, got %s", v.name, file, expected, got)
+			}
+		}
+	}
+
+	b, err := os.ReadFile("a.html")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if !strings.Contains(string(b), "modified") {
+		t.Errorf("expected the read file in a.html, got %s", b)
+	}
+}
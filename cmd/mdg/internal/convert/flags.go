@@ -0,0 +1,265 @@
+package convert
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.iscode.ca/mdg/internal/pkg/list"
+	"go.iscode.ca/mdg/internal/pkg/walk"
+	"go.iscode.ca/mdg/pkg/config"
+	"go.iscode.ca/mdg/pkg/markdown"
+	"go.iscode.ca/mdg/pkg/syntax"
+)
+
+// command is the name of the configuration file section.
+const command = "convert"
+
+// pathFlags are the options containing a path.
+var pathFlags = []string{"cache", "css", "layouts", "template", "o"}
+
+// flags are the command line options.
+type flags struct {
+	baseURL          *string
+	css              *string
+	extendCSS        *bool
+	stylesheets      list.Strings
+	scripts          list.Strings
+	tmpl             *string
+	textTemplate     *bool
+	layouts          *string
+	check            *string
+	cache            *string
+	verbose          *bool
+	outdir           *string
+	noRewriteLinks   *bool
+	noAttribute      *bool
+	noDefinitionList *bool
+	noFootnote       *bool
+	typographer      *bool
+	naming           *string
+	include          list.Strings
+	exclude          list.Strings
+	ext              *string
+	gitignore        *bool
+	jobs             *int
+	keepGoing        *bool
+	report           *string
+	reportFile       *string
+	watch            *bool
+	watchDelay       *time.Duration
+	watchPoll        *bool
+}
+
+func newFlags(fs *flag.FlagSet) *flags {
+	fl := &flags{
+		baseURL:          fs.String("base-url", "", "URL of the HTML files, used by the absURL template function"),
+		css:              fs.String("css", "", "CSS file"),
+		extendCSS:        fs.Bool("extend-css", false, "Append the CSS file to the default CSS instead of replacing it"),
+		tmpl:             fs.String("template", "", "HTML template"),
+		textTemplate:     fs.Bool("text-template", false, "Execute the templates with text/template, without escaping"),
+		layouts:          fs.String("layouts", "", "Layouts directory: templates selected by the layout key of the front matter and partials"),
+		check:            fs.String("check", "newer", "Compare markdown files to HTML before conversion: newer, hash, disable"),
+		cache:            fs.String("cache", "", "Cache file of -check hash (default "+CacheFile+" in the output or working directory)"),
+		verbose:          fs.Bool("verbose", false, "Enable debug messages"),
+		outdir:           fs.String("o", "", "Write HTML to a directory mirroring the markdown source tree"),
+		noRewriteLinks:   fs.Bool("no-rewrite-links", false, "Disable rewriting relative links to markdown files to HTML"),
+		noAttribute:      fs.Bool("no-attribute", false, "Disable heading attributes: # Heading {#custom-id}"),
+		noDefinitionList: fs.Bool("no-definition-list", false, "Disable definition lists"),
+		noFootnote:       fs.Bool("no-footnote", false, "Disable footnotes"),
+		typographer:      fs.Bool("typographer", false, "Replace punctuation with typographic entities: smart quotes, dashes and ellipses"),
+		naming:           fs.String("naming", "ext", "HTML file names: ext (foo.html), index (README.md -> index.html), pretty (foo/index.html)"),
+		ext:              fs.String("ext", ".md,.markdown", "Comma separated list of markdown file extensions"),
+		gitignore:        fs.Bool("gitignore", false, "Skip files and directories listed in .gitignore files"),
+		jobs:             fs.Int("j", 1, "Number of files to convert concurrently (0 uses the number of CPUs)"),
+		keepGoing:        fs.Bool("keep-going", false, "Continue after errors and report a summary of the processed files"),
+		report:           fs.String("report", "", "Report the outcome of each file: text (summary on stderr) or json (on stdout)"),
+		reportFile:       fs.String("report-file", "", "Write the report to the file instead of stderr or stdout"),
+		watch:            fs.Bool("watch", false, "Convert modified files until interrupted, and all files when a template changes"),
+		watchDelay:       fs.Duration("watch-delay", 100*time.Millisecond, "Wait for writes to finish before converting"),
+		watchPoll:        fs.Bool("watch-poll", false, "Poll for modified files instead of using filesystem notifications"),
+	}
+
+	fs.Var(&fl.stylesheets, "stylesheet", "URL of a style sheet linked by the template (repeatable)")
+	fs.Var(&fl.scripts, "script", "URL of a script loaded by the template (repeatable)")
+	fs.Var(&fl.include, "include", "Process files matching the glob pattern (repeatable)")
+	fs.Var(&fl.exclude, "exclude", "Skip files and directories matching the glob pattern (repeatable)")
+
+	return fl
+}
+
+// resolve parses the command line options, overriding the configuration
+// of the directory. The options read from the base configuration only
+// are removed from the configuration of the directory: resolve returns
+// a warning for each option.
+func resolve(dir string, args []string, base *config.Config) (*flags, []string, error) {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	fl := newFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	warnings := cfg.Nested(base, command, fs)
+
+	if err := cfg.Apply(fs, command, pathFlags...); err != nil {
+		return nil, nil, err
+	}
+
+	return fl, warnings, nil
+}
+
+// walker returns the directory walker.
+func (fl *flags) walker() *walk.Walker {
+	return walk.New(
+		walk.WithInclude(fl.include.Values()...),
+		walk.WithExclude(fl.exclude.Values()...),
+		walk.WithExtensions(strings.Split(*fl.ext, ",")...),
+		walk.WithGitIgnore(*fl.gitignore),
+	)
+}
+
+// markdown returns the converter options. The output file names are
+// set by the naming. The template functions resolve paths relative to
+// the source directory.
+func (fl *flags) markdown(n markdown.Naming, root string) (*markdown.Opt, error) {
+	cssContent := ""
+	if *fl.css != "" {
+		b, err := os.ReadFile(*fl.css)
+		if err != nil {
+			return nil, fmt.Errorf("css: %w", err)
+		}
+		cssContent = string(b)
+	}
+
+	var t *markdown.Template
+
+	if *fl.tmpl != "" {
+		b, err := os.ReadFile(*fl.tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("template: %w", err)
+		}
+
+		t, err = markdown.ParseTemplate("index", string(b))
+		if err != nil {
+			return nil, fmt.Errorf("template: %w", err)
+		}
+	}
+
+	return markdown.New(
+		markdown.WithTemplate(t),
+		markdown.WithTextTemplate(*fl.textTemplate),
+		markdown.WithLayouts(*fl.layouts),
+		markdown.WithCSS(cssContent),
+		markdown.WithExtendCSS(*fl.extendCSS),
+		markdown.WithStyles(fl.stylesheets.Values()...),
+		markdown.WithScripts(fl.scripts.Values()...),
+		markdown.WithNaming(n),
+		markdown.WithExtensions(strings.Split(*fl.ext, ",")...),
+		markdown.WithRoot(root),
+		markdown.WithBaseURL(*fl.baseURL),
+		markdown.WithRewriteLinks(!*fl.noRewriteLinks),
+		markdown.WithProfile(syntax.New(
+			syntax.WithAttribute(!*fl.noAttribute),
+			syntax.WithDefinitionList(!*fl.noDefinitionList),
+			syntax.WithFootnote(!*fl.noFootnote),
+		)),
+		markdown.WithTypographer(*fl.typographer),
+	), nil
+}
+
+// digest returns the digest of the options changing the HTML of the
+// documents of the directory: the templates, the CSS, the converter
+// options and the mdg version.
+func (fl *flags) digest(n markdown.Naming, root, dir string) (string, error) {
+	h := sha256.New()
+
+	fmt.Fprintln(h, config.Version())
+	fmt.Fprintln(h, n, *fl.ext, *fl.baseURL, *fl.extendCSS, fl.stylesheets.String(), fl.scripts.String(), *fl.textTemplate, *fl.noRewriteLinks, *fl.noAttribute, *fl.noDefinitionList, *fl.noFootnote, *fl.typographer)
+
+	layouts, err := markdown.LayoutFiles(*fl.layouts, root, dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range append([]string{*fl.tmpl, *fl.css}, layouts...) {
+		fmt.Fprintln(h, file)
+
+		if file == "" {
+			continue
+		}
+
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+
+		_, _ = h.Write(b)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// markdown returns the converter options for the configuration of the
+// directory of the file and the digest of the options if the cache is
+// enabled.
+func (o *Opt) markdown(file string) (*markdown.Opt, string, error) {
+	dir := filepath.Dir(file)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if md, ok := o.mds[dir]; ok {
+		return md, o.digests[dir], nil
+	}
+
+	fl, warnings, err := resolve(dir, o.args, o.cfg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, v := range warnings {
+		if !o.warned[v] {
+			fmt.Fprintln(os.Stderr, "warning:", v)
+			o.warned[v] = true
+		}
+	}
+
+	n, err := markdown.ParseNaming(*fl.naming)
+	if err != nil {
+		return nil, "", err
+	}
+
+	root, _ := o.source(file)
+
+	md, err := fl.markdown(n, root)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if o.cache != nil {
+		d, err := fl.digest(n, root, dir)
+		if err != nil {
+			return nil, "", err
+		}
+		o.digests[dir] = d
+	}
+
+	o.mds[dir] = md
+	o.namings[dir] = n
+
+	return md, o.digests[dir], nil
+}
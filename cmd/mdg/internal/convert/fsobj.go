@@ -6,32 +6,57 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
+
+	"go.iscode.ca/mdg/internal/pkg/inplace"
 )
 
 type fsobj struct {
 	*Opt
 
 	r *os.File
-	w *os.File
+	w *inplace.File
+
+	// options is the digest of the converter options and digest the
+	// digest of the inputs of the HTML file if check is hash.
+	options string
+	digest  string
+
+	// files are the files read by the template.
+	files []string
+
+	// msg receives the verbose messages.
+	msg io.Writer
 }
 
 var ErrSkipMD = errors.New("skip markdown file")
 
 func (rw *fsobj) Open() error {
-	html := strings.TrimSuffix(rw.r.Name(), filepath.Ext(rw.r.Name())) + ".html"
+	html := rw.output(rw.r.Name())
 
-	if !rw.compare(rw.r.Name(), html) {
+	if rw.cache != nil {
+		fresh, err := rw.fresh(html)
+		if err != nil {
+			return err
+		}
+		if fresh {
+			return ErrSkipMD
+		}
+	} else if !rw.force && !rw.compare(rw.r.Name(), html) {
 		return ErrSkipMD
 	}
 
 	if rw.verbose {
-		fmt.Fprintln(os.Stderr, "Converting:", rw.r.Name(), " -> ", html)
+		fmt.Fprintln(rw.msg, "Converting:", rw.r.Name(), " -> ", html)
 	}
 
-	w, err := os.OpenFile(html, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err := os.MkdirAll(filepath.Dir(html), 0755); err != nil {
+		return err
+	}
+
+	// The HTML file is replaced once the conversion succeeds.
+	w, err := inplace.Create(html)
 	if err != nil {
-		return fmt.Errorf("%s: %w", html, err)
+		return err
 	}
 
 	rw.w = w
@@ -39,8 +64,29 @@ func (rw *fsobj) Open() error {
 	return nil
 }
 
+// fresh reports whether the HTML file was generated from the same
+// source and options.
+func (rw *fsobj) fresh(html string) (bool, error) {
+	b, err := io.ReadAll(rw.r)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := rw.r.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	rw.digest = digest(b, rw.options)
+
+	return rw.cache.fresh(html, rw.digest), nil
+}
+
 func (rw *fsobj) Close() error {
-	return rw.w.Close()
+	return rw.w.Commit()
+}
+
+func (rw *fsobj) Abort() error {
+	return rw.w.Abort()
 }
 
 func (rw *fsobj) In() io.Reader {
@@ -5,14 +5,21 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
-	"text/template"
+	"sync"
 
 	"go.iscode.ca/mdg/internal/pkg/fdpair"
+	"go.iscode.ca/mdg/internal/pkg/inplace"
+	"go.iscode.ca/mdg/internal/pkg/pool"
+	"go.iscode.ca/mdg/internal/pkg/report"
+	"go.iscode.ca/mdg/internal/pkg/walk"
+	"go.iscode.ca/mdg/internal/pkg/watch"
 	"go.iscode.ca/mdg/pkg/config"
 	"go.iscode.ca/mdg/pkg/markdown"
 )
@@ -20,7 +27,37 @@ import (
 type Opt struct {
 	verbose bool
 	check   string
-	md      *markdown.Opt
+	// force converts the files whose HTML file is newer, once a template
+	// is modified while watching.
+	force  bool
+	md     *markdown.Opt
+	walker *walk.Walker
+	outdir string
+	naming markdown.Naming
+	roots  []string
+	jobs   int
+
+	// keepGoing continues after errors. The outcome of each file is
+	// recorded in report.
+	keepGoing bool
+	report    *report.Report
+
+	// cache records the digests of the inputs of the HTML files if
+	// check is hash.
+	cache   *cache
+	digests map[string]string
+
+	// mds and namings are the converter options and the naming of the
+	// configuration of each directory. Command line options in args
+	// override the configuration. The options of cfg, the configuration
+	// of the working directory, which are set by the configuration of a
+	// directory are ignored: warned records the warnings displayed.
+	mds     map[string]*markdown.Opt
+	namings map[string]markdown.Naming
+	args    []string
+	cfg     *config.Config
+	warned  map[string]bool
+	mu      sync.Mutex
 }
 
 func usage() {
@@ -35,114 +72,457 @@ Convert markdown documents to HTML.
 }
 
 func Run() {
-	css := flag.String("css", "", "CSS file")
-	tmpl := flag.String("template", "", "HTML template")
-	check := flag.String("check", "newer", "Compare markdown files to HTML before conversion: newer, disable")
-	verbose := flag.Bool("verbose", false, "Enable debug messages")
+	inplace.RemoveOnSignal()
+
+	fl := newFlags(flag.CommandLine)
 
 	flag.Usage = func() { usage() }
 
 	flag.Parse()
 
+	cfg, err := config.Load(".")
+	if err == nil {
+		err = cfg.Apply(flag.CommandLine, command, pathFlags...)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	args := []string{"-"}
 	if flag.NArg() > 0 {
 		args = flag.Args()
 	}
 
-	cssContent := ""
-	if *css != "" {
-		b, err := os.ReadFile(*css)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "css: %v\n", err)
+	n, err := markdown.ParseNaming(*fl.naming)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	md, err := fl.markdown(n, ".")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	o := &Opt{
+		md:      md,
+		walker:  fl.walker(),
+		mds:     make(map[string]*markdown.Opt),
+		namings: make(map[string]markdown.Naming),
+		args:    os.Args[1:],
+		cfg:     cfg,
+		warned:  make(map[string]bool),
+		check:   *fl.check,
+		verbose: *fl.verbose,
+		outdir:  *fl.outdir,
+		naming:  n,
+		roots:   roots(args),
+		jobs:    *fl.jobs,
+		digests: make(map[string]string),
+
+		keepGoing: *fl.keepGoing,
+		report:    report.New(command, report.Converted, report.Skipped, report.Failed),
+	}
+
+	format := *fl.report
+	if format == "" && (o.keepGoing || *fl.reportFile != "") {
+		format = report.FormatText
+	}
+
+	switch format {
+	case "", report.FormatText, report.FormatJSON:
+	default:
+		fmt.Fprintln(os.Stderr, "invalid report:", format)
+		os.Exit(1)
+	}
+
+	// The JSON report is the only output on stdout.
+	if format == report.FormatJSON && *fl.reportFile == "" && slices.Contains(args, "-") {
+		fmt.Fprintln(os.Stderr, "report: stdout is used by the HTML document: use -report-file")
+		os.Exit(1)
+	}
+
+	if o.check == "hash" {
+		if o.cache, err = loadCache(cachePath(*fl.cache, o.outdir)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
-		cssContent = string(b)
 	}
 
-	var t *template.Template
+	var errs []error
+
+	for _, v := range args {
+		if err := o.run(v); err != nil {
+			errs = append(errs, err)
+			if !o.keepGoing {
+				break
+			}
+		}
+	}
+
+	o.save()
+
+	if err := errors.Join(errs...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if err := o.writeReport(format, *fl.reportFile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if len(errs) > 0 && (!o.keepGoing || !*fl.watch) {
+		os.Exit(1)
+	}
+
+	if !*fl.watch {
+		return
+	}
+
+	// Files converted while watching are not reported.
+	o.report = nil
+
+	inputs := []string{*fl.css, *fl.tmpl, *fl.layouts}
+
+	if err := o.watch(args, inputs, watch.WithDelay(*fl.watchDelay), watch.WithPolling(*fl.watchPoll)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// watch converts markdown files as they are modified. The inputs are
+// the CSS file, the template and the layouts directory: if one of them,
+// or a layout of the source directories, is modified, the converters are
+// built again and all the files are converted. Errors are reported and
+// do not stop the watcher.
+func (o *Opt) watch(args, inputs []string, opt ...watch.Option) error {
+	paths := make([]string, 0, len(args))
+	for _, v := range args {
+		if v != "-" {
+			paths = append(paths, v)
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil
+	}
+
+	var watched []string
 
-	if *tmpl != "" {
-		b, err := os.ReadFile(*tmpl)
+	for _, v := range inputs {
+		if v == "" {
+			continue
+		}
+
+		abs, err := filepath.Abs(v)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "template: %v\n", err)
-			os.Exit(1)
+			return err
 		}
 
-		t, err = template.New("index").Parse(string(b))
+		watched = append(watched, abs)
+	}
+
+	// input reports whether the file is read by the converters.
+	input := func(file string) bool {
+		if filepath.Base(file) == markdown.LayoutFile {
+			return true
+		}
+
+		abs, err := filepath.Abs(file)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "template: %v\n", err)
-			os.Exit(1)
+			return false
+		}
+
+		for _, v := range watched {
+			if abs == v || strings.HasPrefix(abs, v+string(filepath.Separator)) {
+				return true
+			}
 		}
+
+		return false
 	}
 
-	o := &Opt{
-		md:      markdown.New(markdown.WithTemplate(t), markdown.WithCSS(cssContent)),
-		check:   *check,
-		verbose: *verbose,
+	filter := func(file string) bool {
+		if input(file) {
+			return true
+		}
+
+		for _, root := range paths {
+			if o.walker.Match(root, file) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	w, err := watch.New(append(paths, watched...), append(opt, watch.WithFilter(filter))...)
+	if err != nil {
+		return err
 	}
 
+	defer w.Close()
+
+	for files := range w.Events() {
+		force := slices.ContainsFunc(files, input)
+
+		if force {
+			o.reset()
+
+			files = nil
+
+			for _, root := range paths {
+				if err := o.walker.Walk(root, func(file string) error {
+					files = append(files, file)
+					return nil
+				}); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}
+		}
+
+		o.force = force
+
+		for _, file := range files {
+			if err := o.file(file, os.Stderr); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+
+		o.save()
+	}
+
+	return nil
+}
+
+// reset removes the converters of the directories: they are built again
+// with the modified templates and CSS files.
+func (o *Opt) reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	clear(o.mds)
+	clear(o.namings)
+	clear(o.digests)
+}
+
+// cachePath returns the path of the cache file.
+func cachePath(file, outdir string) string {
+	if file != "" {
+		return file
+	}
+
+	return filepath.Join(outdir, CacheFile)
+}
+
+// save writes the cache. Errors are reported: the files are converted
+// again on the next run.
+func (o *Opt) save() {
+	if o.cache == nil {
+		return
+	}
+
+	if err := o.cache.save(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// writeReport writes the outcome of each file to the file or, without
+// file, a summary on stderr or JSON on stdout.
+func (o *Opt) writeReport(format, file string) error {
+	switch {
+	case format == "":
+		return nil
+	case file != "":
+		return o.report.WriteFile(file, format)
+	case format == report.FormatJSON:
+		return o.report.Write(os.Stdout, format)
+	default:
+		return o.report.Write(os.Stderr, format)
+	}
+}
+
+// roots returns the source directories of the arguments. The paths of
+// the markdown files relative to their source directory are mirrored in
+// the output directory.
+func roots(args []string) []string {
+	r := make([]string, 0, len(args))
+
 	for _, v := range args {
-		if err := o.run(v); err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+		if v == "-" {
+			continue
+		}
+
+		st, err := os.Stat(v)
+		if err == nil && !st.IsDir() {
+			v = filepath.Dir(v)
 		}
+
+		r = append(r, filepath.Clean(v))
+	}
+
+	return r
+}
+
+// output returns the HTML path for a markdown file.
+func (o *Opt) output(file string) string {
+	n := o.fileNaming(file)
+
+	if o.outdir == "" {
+		return n.Path(file)
 	}
+
+	_, rel := o.source(file)
+
+	return filepath.Join(o.outdir, n.Path(rel))
+}
+
+// fileNaming returns the naming of the configuration of the directory
+// of the markdown file, once the converter options of the directory are
+// resolved, or the naming of the working directory.
+func (o *Opt) fileNaming(file string) markdown.Naming {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if n, ok := o.namings[filepath.Dir(file)]; ok {
+		return n
+	}
+
+	return o.naming
+}
+
+// source returns the source directory of a markdown file and the path
+// of the file relative to the directory. The source directory of the
+// HTML files written next to the markdown files is the working
+// directory.
+func (o *Opt) source(file string) (string, string) {
+	if o.outdir == "" {
+		return ".", file
+	}
+
+	rel := filepath.Base(file)
+	match := ""
+
+	for _, root := range o.roots {
+		p, err := filepath.Rel(root, file)
+		if err != nil || p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) {
+			continue
+		}
+
+		// prefer the most specific source directory
+		if match == "" || len(root) > len(match) {
+			match = root
+			rel = p
+		}
+	}
+
+	if match == "" {
+		return filepath.Dir(file), rel
+	}
+
+	return match, rel
 }
 
 func (o *Opt) run(dir string) error {
 	if dir == "-" {
 		o.check = "disable"
 
-		return o.convert(&stdio{
+		return o.record("-", "-", o.convert(&stdio{
 			r:   os.Stdin,
 			Opt: o,
-		})
+		}))
 	}
 
-	return filepath.WalkDir(dir, o.walkdir)
-}
+	var files []string
 
-func (o *Opt) convert(rw fdpair.FD) error {
-	var in string
+	if err := o.walker.Walk(dir, func(file string) error {
+		files = append(files, file)
+		return nil
+	}); err != nil {
+		o.report.Add(dir, "", report.Failed, err)
+		return err
+	}
 
-	if f, ok := rw.In().(*os.File); ok {
-		in = f.Name()
+	run := pool.Run
+	if o.keepGoing {
+		run = pool.RunAll
 	}
 
-	err := rw.Open()
+	return run(io.Discard, os.Stderr, o.jobs, files, func(file string, _, msg io.Writer) error {
+		return o.file(file, msg)
+	})
+}
 
+// record records the outcome of converting a file: skipped if the HTML
+// file is up to date, failed if err is not nil and converted otherwise.
+func (o *Opt) record(file, output string, err error) error {
 	switch {
-	case err == nil:
 	case errors.Is(err, ErrSkipMD):
+		o.report.Add(file, output, report.Skipped, nil)
 		return nil
-	default:
-		return fmt.Errorf("%s: %w", in, err)
+	case err != nil:
+		o.report.Add(file, output, report.Failed, err)
+		return err
 	}
 
-	var out string
+	o.report.Add(file, output, report.Converted, nil)
 
-	if f, ok := rw.Out().(*os.File); ok {
-		out = f.Name()
+	return nil
+}
+
+// convert converts a markdown document. ErrSkipMD is returned if the
+// HTML file is up to date.
+func (o *Opt) convert(rw fdpair.FD) (err error) {
+	md := o.md
+
+	fsrw, ok := rw.(*fsobj)
+	if ok {
+		if md, fsrw.options, err = o.markdown(fdpair.Name(rw.In())); err != nil {
+			return err
+		}
 	}
 
+	if err := rw.Open(); err != nil {
+		return err
+	}
+
+	out := fdpair.Name(rw.Out())
+
 	defer func() {
-		if rerr := rw.Close(); rerr != nil {
-			if err == nil {
-				err = fmt.Errorf("%s: %w", out, rerr)
-			}
+		// The HTML file is not written if the conversion failed.
+		if err != nil {
+			err = errors.Join(err, rw.Abort())
+			return
+		}
+
+		if err = rw.Close(); err != nil {
+			return
+		}
+
+		// The output is cached once written.
+		if fsrw != nil && fsrw.digest != "" {
+			err = o.cache.set(out, fsrw.digest, fsrw.files)
 		}
 	}()
 
-	if err := o.md.Convert(rw.In(), rw.Out()); err != nil {
-		return fmt.Errorf("%s: %w", out, err)
+	var opt []markdown.ConvertOption
+
+	// The files read by the template are inputs of the HTML file.
+	if fsrw != nil && fsrw.digest != "" {
+		opt = append(opt, markdown.WithReadFile(func(name string) {
+			fsrw.files = append(fsrw.files, name)
+		}))
 	}
 
-	return nil
+	return md.Convert(rw.In(), rw.Out(), opt...)
 }
 
 func (o *Opt) compare(md, html string) bool {
 	switch o.check {
-	case "", "disable":
+	case "", "disable", "hash":
 		return true
 	case "newer":
 	default:
@@ -163,38 +543,27 @@ func (o *Opt) compare(md, html string) bool {
 	return stmd.ModTime().After(sthtml.ModTime())
 }
 
-func (o *Opt) walkdir(file string, d fs.DirEntry, err error) error {
-	if err != nil {
-		return err
-	}
-
-	if d.Type() != 0 {
+// file converts a markdown file to HTML and records the outcome.
+// Messages are written to msg.
+func (o *Opt) file(file string, msg io.Writer) error {
+	r, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
 		return nil
 	}
 
-	if strings.HasPrefix(file, ".") || strings.HasPrefix(file, "_") {
-		return nil
-	}
+	if err == nil {
+		defer r.Close()
 
-	switch filepath.Ext(file) {
-	case ".md", ".markdown":
-	default:
-		return nil
+		err = o.convert(&fsobj{
+			r:   r,
+			msg: msg,
+			Opt: o,
+		})
 	}
 
-	r, err := os.Open(file)
 	if err != nil {
-		return fmt.Errorf("%s: %w", file, err)
+		err = fmt.Errorf("%s: %w", file, err)
 	}
 
-	rw := &fsobj{
-		r:   r,
-		Opt: o,
-	}
-
-	if err := o.convert(rw); err != nil {
-		return fmt.Errorf("%s: %w", file, err)
-	}
-
-	return nil
+	return o.record(file, o.output(file), err)
 }
@@ -0,0 +1,264 @@
+package format
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.iscode.ca/mdg/internal/pkg/list"
+	"go.iscode.ca/mdg/internal/pkg/walk"
+	"go.iscode.ca/mdg/pkg/config"
+	mdformat "go.iscode.ca/mdg/pkg/format"
+	"go.iscode.ca/mdg/pkg/markdown"
+	"go.iscode.ca/mdg/pkg/syntax"
+)
+
+// command is the name of the configuration file section.
+const command = "fmt"
+
+// pathFlags are the options containing a path.
+var pathFlags = []string{"style"}
+
+// flags are the command line options.
+type flags struct {
+	fs *flag.FlagSet
+
+	diff             *bool
+	check            *bool
+	idempotent       *bool
+	copyInPlace      *bool
+	verbose          *bool
+	noLineWrap       *bool
+	width            *int
+	sentences        *bool
+	noAttribute      *bool
+	noDefinitionList *bool
+	noFootnote       *bool
+	noVerify         *bool
+	sortFrontMatter  *bool
+	frontMatterOrder *string
+	styleFile        *string
+	headingStyle     *string
+	bullet           *string
+	emphasis         *string
+	strong           *string
+	codeFence        *string
+	orderedList      *string
+	thematicBreak    *string
+	include          list.Strings
+	exclude          list.Strings
+	ext              *string
+	gitignore        *bool
+	gofmt            *bool
+	jobs             *int
+	keepGoing        *bool
+	report           *string
+	reportFile       *string
+	watch            *bool
+	watchDelay       *time.Duration
+	watchPoll        *bool
+}
+
+func newFlags(fs *flag.FlagSet) *flags {
+	def := mdformat.DefaultStyle()
+
+	fl := &flags{
+		fs:               fs,
+		diff:             fs.Bool("diff", false, "Display formatting changes as diff"),
+		check:            fs.Bool("check", false, "List unformatted files and exit with non-zero status"),
+		idempotent:       fs.Bool("verify-idempotent", false, "Format files twice and list files which second pass differs, with the diff. Files are not modified."),
+		copyInPlace:      fs.Bool("copy-in-place", false, "Copy formatted content into the files instead of replacing them (default for hard linked files)"),
+		verbose:          fs.Bool("verbose", false, "Enable debug messages"),
+		noLineWrap:       fs.Bool("no-linewrap", false, "Disable wrapping of long lines"),
+		width:            fs.Int("width", 0, "Reflow paragraphs to the column width (0 disables reflowing)"),
+		sentences:        fs.Bool("sentence-per-line", false, "Reflow paragraphs to place each sentence on a separate line"),
+		noAttribute:      fs.Bool("no-attribute", false, "Disable heading attributes: # Heading {#custom-id}"),
+		noDefinitionList: fs.Bool("no-definition-list", false, "Disable definition lists"),
+		noFootnote:       fs.Bool("no-footnote", false, "Disable footnotes"),
+		noVerify:         fs.Bool("no-verify", false, "Disable verifying formatting does not change the HTML rendering of documents"),
+		sortFrontMatter:  fs.Bool("sort-front-matter", false, "Sort front matter keys alphabetically"),
+		frontMatterOrder: fs.String("front-matter-order", "", "Comma separated list of front matter keys sorted before remaining keys (implies -sort-front-matter)"),
+		styleFile:        fs.String("style", "", "Load the markdown style from a YAML file"),
+		headingStyle:     fs.String("heading-style", def.Heading, "Heading style: atx or setext"),
+		bullet:           fs.String("bullet", def.Bullet, "Unordered list marker: -, * or + (empty keeps the original marker)"),
+		emphasis:         fs.String("emphasis", def.Emphasis, "Emphasis marker: * or _"),
+		strong:           fs.String("strong", def.Strong, "Strong emphasis marker: ** or __"),
+		codeFence:        fs.String("code-fence", def.CodeFence, "Code fence: ``` or ~~~"),
+		orderedList:      fs.String("ordered-list", def.OrderedList, "Ordered list numbering: sequential or one"),
+		thematicBreak:    fs.String("thematic-break", def.ThematicBreak, "Thematic break: ---, *** or ___"),
+		ext:              fs.String("ext", ".md,.markdown", "Comma separated list of markdown file extensions"),
+		gitignore:        fs.Bool("gitignore", false, "Skip files and directories listed in .gitignore files"),
+		gofmt:            fs.Bool("gofmt", false, "Format the code blocks fenced with the go language with gofmt"),
+		jobs:             fs.Int("j", 1, "Number of files to format concurrently (0 uses the number of CPUs)"),
+		keepGoing:        fs.Bool("keep-going", false, "Continue after errors and report a summary of the processed files"),
+		report:           fs.String("report", "", "Report the outcome of each file: text (summary on stderr) or json (on stdout)"),
+		reportFile:       fs.String("report-file", "", "Write the report to the file instead of stderr or stdout"),
+		watch:            fs.Bool("watch", false, "Format modified files until interrupted"),
+		watchDelay:       fs.Duration("watch-delay", 100*time.Millisecond, "Wait for writes to finish before formatting"),
+		watchPoll:        fs.Bool("watch-poll", false, "Poll for modified files instead of using filesystem notifications"),
+	}
+
+	fs.Var(&fl.include, "include", "Process files matching the glob pattern (repeatable)")
+	fs.Var(&fl.exclude, "exclude", "Skip files and directories matching the glob pattern (repeatable)")
+
+	return fl
+}
+
+// resolve parses the command line options, overriding the configuration
+// of the directory. The options read from the base configuration only
+// are removed from the configuration of the directory: resolve returns
+// a warning for each option.
+func resolve(dir string, args []string, base *config.Config) (*flags, []string, error) {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	fl := newFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	warnings := cfg.Nested(base, command, fs)
+
+	if err := cfg.Apply(fs, command, pathFlags...); err != nil {
+		return nil, nil, err
+	}
+
+	return fl, warnings, nil
+}
+
+// walker returns the directory walker.
+func (fl *flags) walker() *walk.Walker {
+	return walk.New(
+		walk.WithInclude(fl.include.Values()...),
+		walk.WithExclude(fl.exclude.Values()...),
+		walk.WithExtensions(strings.Split(*fl.ext, ",")...),
+		walk.WithGitIgnore(*fl.gitignore),
+	)
+}
+
+// markdown returns the formatter options.
+func (fl *flags) markdown() (*markdown.Opt, error) {
+	style := mdformat.DefaultStyle()
+
+	if *fl.styleFile != "" {
+		var err error
+		if style, err = loadStyle(*fl.styleFile); err != nil {
+			return nil, err
+		}
+	}
+
+	// Options override the style file.
+	fl.fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "heading-style":
+			style.Heading = *fl.headingStyle
+		case "bullet":
+			style.Bullet = *fl.bullet
+		case "emphasis":
+			style.Emphasis = *fl.emphasis
+		case "strong":
+			style.Strong = *fl.strong
+		case "code-fence":
+			style.CodeFence = *fl.codeFence
+		case "ordered-list":
+			style.OrderedList = *fl.orderedList
+		case "thematic-break":
+			style.ThematicBreak = *fl.thematicBreak
+		}
+	})
+
+	if err := style.Validate(); err != nil {
+		return nil, err
+	}
+
+	formatOpts := []mdformat.Option{
+		mdformat.WithWidth(*fl.width),
+		mdformat.WithSentenceBreaks(*fl.sentences),
+		mdformat.WithGoFormat(*fl.gofmt),
+		mdformat.WithStyle(style),
+	}
+
+	if *fl.sortFrontMatter || *fl.frontMatterOrder != "" {
+		var keys []string
+		for _, k := range strings.Split(*fl.frontMatterOrder, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				keys = append(keys, k)
+			}
+		}
+		formatOpts = append(formatOpts, mdformat.WithFrontMatterOrder(keys...))
+	}
+
+	return markdown.New(
+		markdown.WithLineWrap(!*fl.noLineWrap),
+		markdown.WithProfile(syntax.New(
+			syntax.WithAttribute(!*fl.noAttribute),
+			syntax.WithDefinitionList(!*fl.noDefinitionList),
+			syntax.WithFootnote(!*fl.noFootnote),
+		)),
+		markdown.WithFormatOptions(formatOpts...),
+		markdown.WithVerify(!*fl.noVerify),
+	), nil
+}
+
+// markdown returns the formatter options for the configuration of the
+// directory of the file.
+func (o *Opt) markdown(file string) (*markdown.Opt, error) {
+	dir := filepath.Dir(file)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if md, ok := o.mds[dir]; ok {
+		return md, nil
+	}
+
+	fl, warnings, err := resolve(dir, o.args, o.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range warnings {
+		if !o.warned[v] {
+			fmt.Fprintln(os.Stderr, "warning:", v)
+			o.warned[v] = true
+		}
+	}
+
+	md, err := fl.markdown()
+	if err != nil {
+		return nil, err
+	}
+
+	o.mds[dir] = md
+
+	return md, nil
+}
+
+// loadStyle reads the markdown style file.
+func loadStyle(file string) (s mdformat.Style, err error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return s, err
+	}
+
+	defer func() {
+		err = errors.Join(err, r.Close())
+	}()
+
+	if s, err = mdformat.LoadStyle(r); err != nil {
+		return s, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return s, nil
+}
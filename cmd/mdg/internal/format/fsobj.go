@@ -1,28 +1,39 @@
 package format
 
 import (
-	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
-	"path/filepath"
+
+	"go.iscode.ca/mdg/internal/pkg/inplace"
 )
 
 type fsobj struct {
 	*Opt
 
 	r *os.File
-	w *os.File
+	w *inplace.File
+
+	// st is the file information of r before it is read. The file is
+	// not replaced if it was modified since.
+	st fs.FileInfo
+
+	// msg receives the verbose messages.
+	msg io.Writer
 }
 
 func (rw *fsobj) Open() error {
 	if rw.verbose {
-		fmt.Fprintln(os.Stderr, "Formatting:", rw.r.Name())
+		fmt.Fprintln(rw.msg, "Formatting:", rw.r.Name())
 	}
 
-	w, err := os.CreateTemp(filepath.Dir(rw.r.Name()), filepath.Base(rw.r.Name()))
+	w, err := inplace.Create(rw.r.Name(),
+		inplace.WithSource(rw.st),
+		inplace.WithCopy(rw.copyInPlace),
+	)
 	if err != nil {
-		return fmt.Errorf("%s: %w", rw.r.Name(), err)
+		return err
 	}
 
 	rw.w = w
@@ -31,25 +42,11 @@ func (rw *fsobj) Open() error {
 }
 
 func (rw *fsobj) Close() error {
-	err := rw.w.Sync()
-	if err != nil {
-		return fmt.Errorf("%s: %w", rw.w.Name(), err)
-	}
-
-	defer func() {
-		err = errors.Join(err, os.Remove(rw.w.Name()))
-	}()
-
-	err = os.Rename(rw.w.Name(), rw.r.Name())
-	if err != nil {
-		return fmt.Errorf("%s: %w", rw.w.Name(), err)
-	}
-
-	if err := rw.w.Close(); err != nil {
-		return fmt.Errorf("%s: %w", rw.w.Name(), err)
-	}
+	return rw.w.Commit()
+}
 
-	return nil
+func (rw *fsobj) Abort() error {
+	return rw.w.Abort()
 }
 
 func (rw *fsobj) In() io.Reader {
@@ -2,6 +2,7 @@ package format
 
 import (
 	"bytes"
+	"crypto/sha256"
 	_ "embed"
 	"errors"
 	"flag"
@@ -10,21 +11,62 @@ import (
 	"io/fs"
 	"os"
 	"path"
-	"path/filepath"
-	"strings"
+	"slices"
+	"sync"
+	"sync/atomic"
 
 	"go.iscode.ca/mdg/internal/pkg/fdpair"
+	"go.iscode.ca/mdg/internal/pkg/inplace"
+	"go.iscode.ca/mdg/internal/pkg/pool"
+	"go.iscode.ca/mdg/internal/pkg/report"
+	"go.iscode.ca/mdg/internal/pkg/walk"
+	"go.iscode.ca/mdg/internal/pkg/watch"
 	"go.iscode.ca/mdg/pkg/config"
 	"go.iscode.ca/mdg/pkg/markdown"
 
 	"github.com/bwplotka/mdox/pkg/gitdiff"
 )
 
+// ExitUnformatted is the exit status returned by check mode when
+// any file requires formatting.
+const ExitUnformatted = 3
+
 type Opt struct {
-	diff      bool
-	verbose   bool
-	md        *markdown.Opt
-	isChanged func(_, _ []byte) bool
+	diff        bool
+	check       bool
+	unformatted atomic.Int64
+	idempotent  bool
+	unstable    atomic.Int64
+	jobs        int
+	verbose     bool
+	copyInPlace bool
+	md          *markdown.Opt
+	walker      *walk.Walker
+	isChanged   func(_, _ []byte) bool
+
+	// mds are the formatter options of the configuration of each
+	// directory. Command line options in args override the
+	// configuration. The options of cfg, the configuration of the
+	// working directory, which are set by the configuration of a
+	// directory are ignored: warned records the warnings displayed.
+	mds    map[string]*markdown.Opt
+	args   []string
+	cfg    *config.Config
+	warned map[string]bool
+	mu     sync.Mutex
+
+	// written records the checksum of files formatted in place while
+	// watching so the watcher ignores its own writes.
+	written map[string][sha256.Size]byte
+
+	// keepGoing continues after errors. The outcome of each file is
+	// recorded in report.
+	keepGoing bool
+	report    *report.Report
+
+	// quiet does not list the unformatted files: the report lists them
+	// on stdout.
+	quiet bool
 }
 
 func usage() {
@@ -39,141 +81,408 @@ Format markdown documents.
 }
 
 func Run() {
-	diff := flag.Bool("diff", false, "Display formatting changes as diff")
-	verbose := flag.Bool("verbose", false, "Enable debug messages")
-	noLineWrap := flag.Bool("no-linewrap", false, "Disable wrapping of long lines")
+	inplace.RemoveOnSignal()
+
+	fl := newFlags(flag.CommandLine)
 
 	flag.Usage = func() { usage() }
 
 	flag.Parse()
 
+	cfg, err := config.Load(".")
+	if err == nil {
+		err = cfg.Apply(flag.CommandLine, command, pathFlags...)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	args := []string{"-"}
 	if flag.NArg() > 0 {
 		args = flag.Args()
 	}
 
+	md, err := fl.markdown()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	o := &Opt{
-		md:        markdown.New(markdown.WithLineWrap(!*noLineWrap)),
-		diff:      *diff,
-		verbose:   *verbose,
-		isChanged: func(_, _ []byte) bool { return true },
+		md:          md,
+		walker:      fl.walker(),
+		jobs:        *fl.jobs,
+		mds:         make(map[string]*markdown.Opt),
+		args:        os.Args[1:],
+		cfg:         cfg,
+		warned:      make(map[string]bool),
+		diff:        *fl.diff,
+		check:       *fl.check,
+		verbose:     *fl.verbose,
+		copyInPlace: *fl.copyInPlace,
+		idempotent:  *fl.idempotent,
+		isChanged:   func(_, _ []byte) bool { return true },
+		keepGoing:   *fl.keepGoing,
+		report:      report.New(command, report.Formatted, report.Skipped, report.Failed),
+	}
+
+	if o.check {
+		o.isChanged = changed
+	}
+
+	if o.check || o.diff {
+		o.report = report.New(command, report.Unformatted, report.Skipped, report.Failed)
+	}
+
+	if o.idempotent {
+		o.report = report.New(command, report.Idempotent, report.Failed)
+	}
+
+	format := *fl.report
+	if format == "" && (o.keepGoing || *fl.reportFile != "") {
+		format = report.FormatText
 	}
 
+	switch format {
+	case "", report.FormatText, report.FormatJSON:
+	default:
+		fmt.Fprintln(os.Stderr, "invalid report:", format)
+		os.Exit(1)
+	}
+
+	// The JSON report is the only output on stdout: it lists the
+	// unformatted files.
+	if format == report.FormatJSON && *fl.reportFile == "" {
+		if o.diff || o.idempotent || slices.Contains(args, "-") {
+			fmt.Fprintln(os.Stderr, "report: stdout is used by -diff, -verify-idempotent or the formatted document: use -report-file")
+			os.Exit(1)
+		}
+
+		o.quiet = true
+	}
+
+	var errs []error
+
 	for _, v := range args {
 		if err := o.run(v); err != nil {
+			errs = append(errs, err)
+			if !o.keepGoing {
+				break
+			}
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if err := o.writeReport(format, *fl.reportFile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if len(errs) > 0 && (!o.keepGoing || !*fl.watch) {
+		os.Exit(1)
+	}
+
+	if *fl.watch {
+		// Files formatted while watching are not reported.
+		o.report = nil
+
+		if err := o.watch(args, watch.WithDelay(*fl.watchDelay), watch.WithPolling(*fl.watchPoll)); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 	}
+
+	if o.unstable.Load() > 0 {
+		os.Exit(1)
+	}
+
+	if o.unformatted.Load() > 0 {
+		os.Exit(ExitUnformatted)
+	}
+}
+
+// watch formats markdown files as they are modified. Errors are
+// reported and do not stop the watcher.
+func (o *Opt) watch(args []string, opt ...watch.Option) error {
+	paths := make([]string, 0, len(args))
+	for _, v := range args {
+		if v != "-" {
+			paths = append(paths, v)
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil
+	}
+
+	o.isChanged = changed
+	o.written = make(map[string][sha256.Size]byte)
+
+	filter := func(file string) bool {
+		for _, root := range paths {
+			if o.walker.Match(root, file) {
+				return true
+			}
+		}
+		return false
+	}
+
+	w, err := watch.New(paths, append(opt, watch.WithFilter(filter))...)
+	if err != nil {
+		return err
+	}
+
+	defer w.Close()
+
+	for files := range w.Events() {
+		for _, file := range files {
+			if err := o.file(file, os.Stdout, os.Stderr); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeReport writes the outcome of each file to the file or, without
+// file, a summary on stderr or JSON on stdout.
+func (o *Opt) writeReport(format, file string) error {
+	switch {
+	case format == "":
+		return nil
+	case file != "":
+		return o.report.WriteFile(file, format)
+	case format == report.FormatJSON:
+		return o.report.Write(os.Stdout, format)
+	default:
+		return o.report.Write(os.Stderr, format)
+	}
+}
+
+func changed(in, out []byte) bool {
+	return !bytes.Equal(in, out)
 }
 
 func (o *Opt) run(dir string) error {
 	if dir == "-" {
-		return o.format(&stdio{
+		status, err := o.format(&stdio{
 			r:   os.Stdin,
 			Opt: o,
-		})
+		}, os.Stdout)
+
+		return o.record(dir, status, err)
 	}
 
-	o.isChanged = func(in, out []byte) bool {
-		return !bytes.Equal(in, out)
+	o.isChanged = changed
+
+	var files []string
+
+	if err := o.walker.Walk(dir, func(file string) error {
+		files = append(files, file)
+		return nil
+	}); err != nil {
+		o.report.Add(dir, "", report.Failed, err)
+		return err
+	}
+
+	run := pool.Run
+	if o.keepGoing {
+		run = pool.RunAll
+	}
+
+	return run(os.Stdout, os.Stderr, o.jobs, files, func(file string, w, msg io.Writer) error {
+		return o.file(file, w, msg)
+	})
+}
+
+// errUnstable is the error of the files which second pass differs. The
+// other files are verified: Run exits with an error once all the files
+// are verified.
+var errUnstable = errors.New("second pass differs")
+
+// record records the outcome of formatting a file. The status of a
+// failed file is failed.
+func (o *Opt) record(file, status string, err error) error {
+	if err != nil {
+		status = report.Failed
+	}
+
+	o.report.Add(file, "", status, err)
+
+	if errors.Is(err, errUnstable) {
+		return nil
 	}
 
-	return filepath.WalkDir(dir, o.walkdir)
+	return err
 }
 
-func (o *Opt) format(rw fdpair.FD) error {
+// format formats a markdown document and returns the outcome. Messages
+// are written to w.
+func (o *Opt) format(rw fdpair.FD, w io.Writer) (status string, err error) {
 	b, err := io.ReadAll(rw.In())
 	if err != nil {
-		return err
+		return report.Failed, err
+	}
+
+	in := fdpair.Name(rw.In())
+
+	if o.written != nil {
+		if sum, ok := o.written[in]; ok && sum == sha256.Sum256(b) {
+			return report.Skipped, nil
+		}
+	}
+
+	md := o.md
+
+	fsrw, ok := rw.(*fsobj)
+	if ok {
+		if md, err = o.markdown(in); err != nil {
+			return report.Failed, err
+		}
+	}
+
+	// The report names the standard input -.
+	file := in
+	if fsrw == nil {
+		file = "-"
 	}
 
 	var formatted bytes.Buffer
 
 	unformatted := bytes.NewBuffer(b)
 
-	var in string
+	if err := md.Format(unformatted, &formatted); err != nil {
+		return report.Failed, err
+	}
+
+	if o.idempotent {
+		return o.verifyIdempotent(md, file, formatted.Bytes(), w)
+	}
 
-	if f, ok := rw.In().(*os.File); ok {
-		in = f.Name()
+	if o.check || o.diff {
+		status = report.Skipped
+		if changed(formatted.Bytes(), b) {
+			status = report.Unformatted
+		}
 	}
 
-	if err := o.md.Format(unformatted, &formatted); err != nil {
-		return fmt.Errorf("%s: %w", in, err)
+	if o.check {
+		if !o.isChanged(formatted.Bytes(), b) {
+			return status, nil
+		}
+
+		o.unformatted.Add(1)
+
+		if !o.diff {
+			if !o.quiet {
+				fmt.Fprintln(w, in)
+			}
+			return status, nil
+		}
 	}
 
 	if o.diff {
 		d := gitdiff.CompareBytes(
-			unformatted.Bytes(), in,
+			b, in,
 			formatted.Bytes(), fmt.Sprintf("%s (formatted)", in),
 		)
 
-		fmt.Println(string(d.ToCombinedFormat()))
+		fmt.Fprintln(w, string(d.ToCombinedFormat()))
 
-		return nil
+		return status, nil
 	}
 
-	if !o.isChanged(formatted.Bytes(), unformatted.Bytes()) {
-		return nil
+	if !o.isChanged(formatted.Bytes(), b) {
+		return report.Skipped, nil
 	}
 
 	if err := rw.Open(); err != nil {
-		return fmt.Errorf("%s: %w", in, err)
-	}
-
-	var out string
-
-	if f, ok := rw.Out().(*os.File); ok {
-		out = f.Name()
+		return report.Failed, err
 	}
 
 	defer func() {
-		err = errors.Join(err, rw.Close())
+		// The file is not replaced if the content was not written.
+		if err != nil {
+			err = errors.Join(err, rw.Abort())
+			return
+		}
+
+		if err = rw.Close(); err != nil {
+			status = report.Failed
+		}
 	}()
 
 	if _, err := rw.Out().Write(formatted.Bytes()); err != nil {
-		return fmt.Errorf("%s: %w", out, err)
+		return report.Failed, err
 	}
 
-	return nil
+	if o.written != nil {
+		o.written[in] = sha256.Sum256(formatted.Bytes())
+	}
+
+	return report.Formatted, nil
 }
 
-func (o *Opt) walkdir(file string, d fs.DirEntry, err error) error {
-	if err != nil {
-		return err
-	}
+// verifyIdempotent formats the formatted document again. If the second
+// pass differs, the differences are written to w and errUnstable is
+// returned.
+func (o *Opt) verifyIdempotent(md *markdown.Opt, file string, formatted []byte, w io.Writer) (string, error) {
+	var second bytes.Buffer
 
-	if d.Type() != 0 {
-		return nil
+	if err := md.Format(bytes.NewReader(formatted), &second); err != nil {
+		return report.Failed, fmt.Errorf("second pass: %w", err)
 	}
 
-	if strings.HasPrefix(file, ".") || strings.HasPrefix(file, "_") {
-		return nil
+	if !changed(formatted, second.Bytes()) {
+		return report.Idempotent, nil
 	}
 
-	switch filepath.Ext(file) {
-	case ".md", ".markdown":
-	default:
-		return nil
-	}
+	o.unstable.Add(1)
+
+	d := gitdiff.CompareBytes(
+		formatted, fmt.Sprintf("%s (formatted)", file),
+		second.Bytes(), fmt.Sprintf("%s (formatted twice)", file),
+	)
+
+	fmt.Fprintln(w, string(d.ToCombinedFormat()))
+
+	return report.Failed, errUnstable
+}
 
+// file formats a markdown file in place and records the outcome. The
+// output is written to w and the messages to msg.
+func (o *Opt) file(file string, w, msg io.Writer) error {
 	r, err := os.Open(file)
-	if err != nil {
-		return fmt.Errorf("%s: %w", file, err)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
 	}
 
-	defer func() {
-		err = errors.Join(err, r.Close())
-	}()
+	var status string
 
-	rw := &fsobj{
-		r:   r,
-		Opt: o,
+	if err == nil {
+		defer r.Close()
+
+		var st fs.FileInfo
+
+		if st, err = r.Stat(); err == nil {
+			status, err = o.format(&fsobj{
+				r:   r,
+				st:  st,
+				msg: msg,
+				Opt: o,
+			}, w)
+		}
 	}
 
-	if err := o.format(rw); err != nil {
-		return fmt.Errorf("%s: %w", file, err)
+	if err != nil && !errors.Is(err, errUnstable) {
+		err = fmt.Errorf("%s: %w", file, err)
 	}
 
-	return nil
+	return o.record(file, status, err)
 }
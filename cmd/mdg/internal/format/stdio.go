@@ -21,6 +21,10 @@ func (rw *stdio) Close() error {
 	return nil
 }
 
+func (rw *stdio) Abort() error {
+	return nil
+}
+
 func (rw *stdio) In() io.Reader {
 	return rw.r
 }
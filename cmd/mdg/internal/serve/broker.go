@@ -0,0 +1,76 @@
+package serve
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// broker distributes reload events to connected browsers.
+type broker struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+func newBroker() *broker {
+	return &broker{
+		clients: make(map[chan struct{}]struct{}),
+	}
+}
+
+func (b *broker) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	b.clients[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch
+}
+
+func (b *broker) unsubscribe(ch chan struct{}) {
+	b.mu.Lock()
+	delete(b.clients, ch)
+	b.mu.Unlock()
+}
+
+func (b *broker) notify() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ServeHTTP streams reload events to the client.
+func (b *broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := b.subscribe()
+	defer b.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			fmt.Fprint(w, "event: reload\ndata: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
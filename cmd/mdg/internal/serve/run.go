@@ -0,0 +1,340 @@
+package serve
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.iscode.ca/mdg/internal/pkg/list"
+	"go.iscode.ca/mdg/internal/pkg/walk"
+	"go.iscode.ca/mdg/internal/pkg/watch"
+	"go.iscode.ca/mdg/pkg/config"
+	"go.iscode.ca/mdg/pkg/markdown"
+)
+
+// EventPath is the URL path of the server-sent event stream used to
+// reload browser tabs.
+const EventPath = "/.mdg/events"
+
+const reloadScript = `<script>
+new EventSource("` + EventPath + `").addEventListener("reload", function() {
+	location.reload();
+});
+</script>
+`
+
+type Opt struct {
+	root        string
+	css         string
+	extendCSS   bool
+	stylesheets []string
+	scripts     []string
+	tmpl        string
+	textTmpl    bool
+	layouts     string
+	verbose     bool
+	walker      *walk.Walker
+	events      *broker
+
+	// md is the converter, built again when the template, the CSS file
+	// or a layout is modified. err is the error of the last build.
+	md  *markdown.Opt
+	err error
+	mu  sync.RWMutex
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `%s %s
+Usage: %s serve [<option>] [<directory>]
+
+Serve markdown documents as HTML, reloading the browser on changes.
+
+`, path.Base(os.Args[0]), config.Version(), os.Args[0])
+	fmt.Fprintf(os.Stderr, "Options:\n\n")
+	flag.PrintDefaults()
+}
+
+func Run() {
+	addr := flag.String("addr", "localhost:8080", "Listen address")
+	css := flag.String("css", "", "CSS file")
+	extendCSS := flag.Bool("extend-css", false, "Append the CSS file to the default CSS instead of replacing it")
+	tmpl := flag.String("template", "", "HTML template")
+	textTemplate := flag.Bool("text-template", false, "Execute the templates with text/template, without escaping")
+	layouts := flag.String("layouts", "", "Layouts directory: templates selected by the layout key of the front matter and partials")
+	interval := flag.Duration("interval", 500*time.Millisecond, "Interval between checks for changed files when polling")
+	watchDelay := flag.Duration("watch-delay", 100*time.Millisecond, "Wait for writes to finish before reloading")
+	watchPoll := flag.Bool("watch-poll", false, "Poll for modified files instead of using filesystem notifications")
+	verbose := flag.Bool("verbose", false, "Enable debug messages")
+	ext := flag.String("ext", ".md,.markdown", "Comma separated list of markdown file extensions")
+	gitignore := flag.Bool("gitignore", false, "Skip files and directories listed in .gitignore files")
+
+	var stylesheets, scripts, include, exclude list.Strings
+
+	flag.Var(&stylesheets, "stylesheet", "URL of a style sheet linked by the template (repeatable)")
+	flag.Var(&scripts, "script", "URL of a script loaded by the template (repeatable)")
+	flag.Var(&include, "include", "Serve the markdown documents matching the glob pattern (repeatable)")
+	flag.Var(&exclude, "exclude", "Do not serve files and directories matching the glob pattern (repeatable)")
+
+	flag.Usage = func() { usage() }
+
+	flag.Parse()
+
+	cfg, err := config.Load(".")
+	if err == nil {
+		err = cfg.Apply(flag.CommandLine, "serve", "css", "layouts", "template")
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	root := "."
+	if flag.NArg() > 0 {
+		root = flag.Arg(0)
+	}
+
+	o := &Opt{
+		root:        root,
+		css:         *css,
+		extendCSS:   *extendCSS,
+		stylesheets: stylesheets.Values(),
+		scripts:     scripts.Values(),
+		tmpl:        *tmpl,
+		textTmpl:    *textTemplate,
+		layouts:     *layouts,
+		verbose:     *verbose,
+		events:      newBroker(),
+		walker: walk.New(
+			walk.WithInclude(include.Values()...),
+			walk.WithExclude(exclude.Values()...),
+			walk.WithExtensions(strings.Split(*ext, ",")...),
+			walk.WithGitIgnore(*gitignore),
+		),
+	}
+
+	// Fail early on an invalid template or CSS file.
+	if o.build(); o.err != nil {
+		fmt.Fprintln(os.Stderr, o.err)
+		os.Exit(1)
+	}
+
+	w, err := o.watch(
+		watch.WithDelay(*watchDelay),
+		watch.WithInterval(*interval),
+		watch.WithPolling(*watchPoll),
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	go o.reload(w)
+
+	mux := http.NewServeMux()
+	mux.Handle(EventPath, o.events)
+	mux.HandleFunc("/", o.serve)
+
+	if o.verbose {
+		fmt.Fprintf(os.Stderr, "Serving: %s on http://%s/\n", o.root, *addr)
+	}
+
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// build builds the converter.
+func (o *Opt) build() {
+	md, err := o.markdown()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.md, o.err = md, err
+}
+
+// markdown loads the template and CSS.
+func (o *Opt) markdown() (*markdown.Opt, error) {
+	cssContent := ""
+	if o.css != "" {
+		b, err := os.ReadFile(o.css)
+		if err != nil {
+			return nil, fmt.Errorf("css: %w", err)
+		}
+		cssContent = string(b)
+	}
+
+	var t *markdown.Template
+
+	if o.tmpl != "" {
+		b, err := os.ReadFile(o.tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("template: %w", err)
+		}
+
+		t, err = markdown.ParseTemplate("index", string(b))
+		if err != nil {
+			return nil, fmt.Errorf("template: %w", err)
+		}
+	}
+
+	// Links to markdown documents are converted on request.
+	return markdown.New(
+		markdown.WithTemplate(t),
+		markdown.WithTextTemplate(o.textTmpl),
+		markdown.WithLayouts(o.layouts),
+		markdown.WithCSS(cssContent),
+		markdown.WithExtendCSS(o.extendCSS),
+		markdown.WithStyles(o.stylesheets...),
+		markdown.WithScripts(o.scripts...),
+		markdown.WithRewriteLinks(false),
+		markdown.WithRoot(o.root),
+	), nil
+}
+
+func (o *Opt) serve(w http.ResponseWriter, r *http.Request) {
+	file := filepath.Join(o.root, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+
+	// Hidden and ignored files, such as .git and .mdg.yaml, are not
+	// served.
+	if o.walker.Ignored(o.root, file) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !o.walker.IsMarkdown(file) {
+		http.FileServer(http.Dir(o.root)).ServeHTTP(w, r)
+		return
+	}
+
+	if !o.walker.Match(o.root, file) {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := http.Dir(o.root).Open(r.URL.Path)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, fs.ErrNotExist) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	defer f.Close()
+
+	if o.verbose {
+		fmt.Fprintln(os.Stderr, "Converting:", r.URL.Path)
+	}
+
+	o.mu.RLock()
+	md, err := o.md, o.err
+	o.mu.RUnlock()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var b bytes.Buffer
+
+	if err := md.Convert(f, &b); err != nil {
+		http.Error(w, fmt.Sprintf("%s: %v", r.URL.Path, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(inject(b.Bytes()))
+}
+
+// inject inserts the reload script before the closing body tag or
+// appends it to the document.
+func inject(b []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(b), []byte("</body>"))
+	if i < 0 {
+		return append(b, reloadScript...)
+	}
+
+	out := make([]byte, 0, len(b)+len(reloadScript))
+	out = append(out, b[:i]...)
+	out = append(out, reloadScript...)
+	return append(out, b[i:]...)
+}
+
+// watch starts watching the markdown documents, the templates and the
+// CSS file.
+func (o *Opt) watch(opt ...watch.Option) (*watch.Watcher, error) {
+	paths := []string{o.root}
+
+	for _, file := range []string{o.css, o.tmpl, o.layouts} {
+		if file != "" {
+			paths = append(paths, file)
+		}
+	}
+
+	filter := func(file string) bool {
+		return o.walker.IsMarkdown(file) || o.input(file)
+	}
+
+	return watch.New(paths, append(opt, watch.WithFilter(filter))...)
+}
+
+// input reports whether the file is read by the converter: the CSS file,
+// the template or a layout.
+func (o *Opt) input(file string) bool {
+	if filepath.Base(file) == markdown.LayoutFile {
+		return true
+	}
+
+	// The files are compared as absolute paths: they may be outside of
+	// the root directory.
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+
+	for _, v := range []string{o.css, o.tmpl, o.layouts} {
+		if v == "" {
+			continue
+		}
+
+		if v, err = filepath.Abs(v); err != nil {
+			continue
+		}
+
+		if abs == v || strings.HasPrefix(abs, v+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reload notifies connected clients when a watched file is modified.
+// The converter is built again if one of its inputs is modified.
+func (o *Opt) reload(w *watch.Watcher) {
+	for files := range w.Events() {
+		if o.verbose {
+			fmt.Fprintln(os.Stderr, "Reloading:", strings.Join(files, " "))
+		}
+
+		if slices.ContainsFunc(files, o.input) {
+			o.build()
+		}
+
+		o.events.notify()
+	}
+}
@@ -0,0 +1,205 @@
+package serve
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.iscode.ca/mdg/internal/pkg/walk"
+)
+
+func TestInject(t *testing.T) {
+	for _, v := range []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{"body", "<html><body><p>a</p></body></html>", "<html><body><p>a</p>" + reloadScript + "</body></html>"},
+		{"upper case", "<BODY>a</BODY>", "<BODY>a" + reloadScript + "</BODY>"},
+		{"last body", "<body><pre></body></pre></body>", "<body><pre></body></pre>" + reloadScript + "</body>"},
+		{"no body", "<p>a</p>", "<p>a</p>" + reloadScript},
+	} {
+		if got := string(inject([]byte(v.html))); got != v.expected {
+			t.Errorf("%s: expected %q, got %q", v.name, v.expected, got)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	b := newBroker()
+
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+EventPath, nil)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %s", ct)
+	}
+
+	r := bufio.NewReader(resp.Body)
+
+	// The client is subscribed once the comment is received.
+	if line, err := r.ReadString('\n'); err != nil || line != ": connected\n" {
+		t.Errorf("expected connected comment, got %q: %v", line, err)
+		return
+	}
+
+	if _, err := r.ReadString('\n'); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	b.notify()
+
+	for _, expected := range []string{"event: reload\n", "data: reload\n", "\n"} {
+		if line, err := r.ReadString('\n'); err != nil || line != expected {
+			t.Errorf("expected %q, got %q: %v", expected, line, err)
+			return
+		}
+	}
+
+	cancel()
+
+	// The client is unsubscribed when the request is done.
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		b.mu.Lock()
+		n := len(b.clients)
+		b.mu.Unlock()
+
+		if n == 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Errorf("expected the client to be unsubscribed")
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServe(t *testing.T) {
+	root := t.TempDir()
+
+	for name, content := range map[string]string{
+		"a.md":          "# A\n",
+		"img.png":       "png",
+		".mdg.yaml":     "css: secret.css\n",
+		".git/config":   "[core]\n",
+		"_drafts/b.md":  "# B\n",
+		"build/c.md":    "# C\n",
+		"build/c.png":   "png",
+		".mdgignore":    "build/\n",
+		"sub/.hidden":   "",
+		"sub/d.mdown":   "# D\n",
+		"sub/page.html": "<p>page</p>",
+	} {
+		file := filepath.Join(root, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+
+	o := &Opt{
+		root:   root,
+		walker: walk.New(walk.WithExtensions(".md", ".mdown")),
+		events: newBroker(),
+	}
+
+	o.build()
+
+	if o.err != nil {
+		t.Errorf("%v", o.err)
+		return
+	}
+
+	for _, v := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/a.md", http.StatusOK, reloadScript},
+		{"/sub/d.mdown", http.StatusOK, "<h1"},
+		{"/img.png", http.StatusOK, "png"},
+		{"/sub/page.html", http.StatusOK, "<p>page</p>"},
+		{"/missing.md", http.StatusNotFound, ""},
+		{"/.mdg.yaml", http.StatusNotFound, ""},
+		{"/.git/config", http.StatusNotFound, ""},
+		{"/.git/", http.StatusNotFound, ""},
+		{"/_drafts/b.md", http.StatusNotFound, ""},
+		{"/build/c.md", http.StatusNotFound, ""},
+		{"/build/c.png", http.StatusNotFound, ""},
+		{"/sub/.hidden", http.StatusNotFound, ""},
+		{"/sub/../.mdg.yaml", http.StatusNotFound, ""},
+	} {
+		w := httptest.NewRecorder()
+
+		o.serve(w, httptest.NewRequest(http.MethodGet, v.path, nil))
+
+		if w.Code != v.status {
+			t.Errorf("%s: expected status %d, got %d", v.path, v.status, w.Code)
+			continue
+		}
+
+		if !strings.Contains(w.Body.String(), v.body) {
+			t.Errorf("%s: expected %q in %q", v.path, v.body, w.Body.String())
+		}
+	}
+}
+
+func TestInput(t *testing.T) {
+	root := t.TempDir()
+
+	o := &Opt{
+		root:    root,
+		css:     filepath.Join(root, "style.css"),
+		layouts: filepath.Join(root, "layouts"),
+	}
+
+	for _, v := range []struct {
+		file     string
+		expected bool
+	}{
+		{"style.css", true},
+		{"layouts/post.html", true},
+		{"docs/_layout.html", true},
+		{"layouts.html", false},
+		{"docs/a.md", false},
+	} {
+		if got := o.input(filepath.Join(root, filepath.FromSlash(v.file))); got != v.expected {
+			t.Errorf("%s: expected %v, got %v", v.file, v.expected, got)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.iscode.ca/mdg/pkg/markdown"
+)
+
+// build converts the source tree to HTML in the output directory.
+func (o *Opt) build() error {
+	root, assets, err := o.scan()
+	if err != nil {
+		return err
+	}
+
+	if root == nil {
+		return fmt.Errorf("%s: no markdown documents found", o.root)
+	}
+
+	var pages []*page
+	root.each(func(p *page) {
+		pages = append(pages, p)
+	})
+
+	dirs := make(map[*page]*dir)
+	var index func(*dir)
+	index = func(d *dir) {
+		dirs[d.index] = d
+		for _, sub := range d.dirs {
+			index(sub)
+		}
+	}
+	index(root)
+
+	for i, p := range pages {
+		var prev, next *markdown.Link
+
+		if i > 0 {
+			prev = &markdown.Link{Title: pages[i-1].title, URL: url(p, pages[i-1])}
+		}
+
+		if i < len(pages)-1 {
+			next = &markdown.Link{Title: pages[i+1].title, URL: url(p, pages[i+1])}
+		}
+
+		setNav := func(m *markdown.Metadata) {
+			m.Menu = template.HTML(menu(root, p))
+			m.Prev = prev
+			m.Next = next
+			if m.Title == "" {
+				m.Title = p.title
+			}
+		}
+
+		if err := o.write(p, dirs[p], setNav); err != nil {
+			return err
+		}
+	}
+
+	for _, rel := range assets {
+		if o.verbose {
+			fmt.Fprintln(os.Stderr, "Copying:", filepath.Join(o.root, rel))
+		}
+
+		if err := o.copyAsset(rel); err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+	}
+
+	return nil
+}
+
+// write converts a document or generates a directory index.
+func (o *Opt) write(p *page, d *dir, opt markdown.ConvertOption) error {
+	out := filepath.Join(o.outdir, p.out)
+
+	var r io.Reader
+
+	if p.src == "" {
+		if o.verbose {
+			fmt.Fprintln(os.Stderr, "Generating:", out)
+		}
+
+		r = bytes.NewBufferString(listing(d))
+	} else {
+		if o.verbose {
+			fmt.Fprintln(os.Stderr, "Converting:", p.src, " -> ", out)
+		}
+
+		f, err := os.Open(p.src)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		r = f
+	}
+
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return err
+	}
+
+	w, err := os.OpenFile(out, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("%s: %w", out, err)
+	}
+
+	if err := o.md.Convert(r, w, opt); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("%s: %w", out, err)
+	}
+
+	return w.Close()
+}
+
+// listing generates a markdown index of the documents and
+// subdirectories of a directory.
+func listing(d *dir) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", escape(d.title))
+
+	for _, p := range d.pages {
+		fmt.Fprintf(&b, "* [%s](%s)", escape(p.title), url(d.index, p))
+		if !p.date.IsZero() {
+			fmt.Fprintf(&b, " (%s)", p.date.Format(time.DateOnly))
+		}
+		b.WriteString("\n")
+	}
+
+	for _, sub := range d.dirs {
+		fmt.Fprintf(&b, "* [%s/](%s)\n", escape(sub.title), url(d.index, sub.index))
+	}
+
+	return b.String()
+}
+
+// escape escapes markdown punctuation in text.
+func escape(s string) string {
+	var b strings.Builder
+
+	for _, c := range s {
+		if strings.ContainsRune("\\`*_[]()#<>!|", c) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// menu renders the navigation tree for a page.
+func menu(root *dir, cur *page) string {
+	var b strings.Builder
+
+	b.WriteString("<ul>")
+	item(&b, root.index, cur, nil)
+	for _, p := range root.pages {
+		item(&b, p, cur, nil)
+	}
+	for _, sub := range root.dirs {
+		menuDir(&b, sub, cur)
+	}
+	b.WriteString("</ul>")
+
+	return b.String()
+}
+
+func menuDir(b *strings.Builder, d *dir, cur *page) {
+	item(b, d.index, cur, func() {
+		if len(d.pages) == 0 && len(d.dirs) == 0 {
+			return
+		}
+
+		b.WriteString("<ul>")
+		for _, p := range d.pages {
+			item(b, p, cur, nil)
+		}
+		for _, sub := range d.dirs {
+			menuDir(b, sub, cur)
+		}
+		b.WriteString("</ul>")
+	})
+}
+
+func item(b *strings.Builder, p, cur *page, children func()) {
+	if p == cur {
+		b.WriteString(`<li class="active">`)
+	} else {
+		b.WriteString("<li>")
+	}
+
+	fmt.Fprintf(b, `<a href="%s">%s</a>`, html.EscapeString(url(cur, p)), html.EscapeString(p.title))
+
+	if children != nil {
+		children()
+	}
+
+	b.WriteString("</li>")
+}
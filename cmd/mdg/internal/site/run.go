@@ -0,0 +1,150 @@
+package site
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go.iscode.ca/mdg/internal/pkg/list"
+	"go.iscode.ca/mdg/internal/pkg/walk"
+	"go.iscode.ca/mdg/pkg/config"
+	"go.iscode.ca/mdg/pkg/markdown"
+)
+
+type Opt struct {
+	root    string
+	outdir  string
+	title   string
+	naming  markdown.Naming
+	verbose bool
+	md      *markdown.Opt
+	walker  *walk.Walker
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `%s %s
+Usage: %s site [<option>] [<directory>]
+
+Build a static site from a directory of markdown documents.
+
+`, path.Base(os.Args[0]), config.Version(), os.Args[0])
+	fmt.Fprintf(os.Stderr, "Options:\n\n")
+	flag.PrintDefaults()
+}
+
+func Run() {
+	baseURL := flag.String("base-url", "", "URL of the site, used by the absURL template function")
+	css := flag.String("css", "", "CSS file")
+	extendCSS := flag.Bool("extend-css", false, "Append the CSS file to the default CSS instead of replacing it")
+	tmpl := flag.String("template", "", "HTML template")
+	textTemplate := flag.Bool("text-template", false, "Execute the templates with text/template, without escaping")
+	layouts := flag.String("layouts", "", "Layouts directory: templates selected by the layout key of the front matter and partials")
+	outdir := flag.String("o", "public", "Output directory")
+	title := flag.String("title", "", "Site title (default: source directory name)")
+	naming := flag.String("naming", "index", "HTML file names: ext (foo.html), index (README.md -> index.html), pretty (foo/index.html)")
+	verbose := flag.Bool("verbose", false, "Enable debug messages")
+	ext := flag.String("ext", ".md,.markdown", "Comma separated list of markdown file extensions")
+	gitignore := flag.Bool("gitignore", false, "Skip files and directories listed in .gitignore files")
+
+	var stylesheets, scripts, include, exclude list.Strings
+
+	flag.Var(&stylesheets, "stylesheet", "URL of a style sheet linked by the template (repeatable)")
+	flag.Var(&scripts, "script", "URL of a script loaded by the template (repeatable)")
+	flag.Var(&include, "include", "Process files matching the glob pattern (repeatable)")
+	flag.Var(&exclude, "exclude", "Skip files and directories matching the glob pattern (repeatable)")
+
+	flag.Usage = func() { usage() }
+
+	flag.Parse()
+
+	cfg, err := config.Load(".")
+	if err == nil {
+		err = cfg.Apply(flag.CommandLine, "site", "css", "layouts", "template", "o")
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	root := "."
+	if flag.NArg() > 0 {
+		root = flag.Arg(0)
+	}
+
+	cssContent := ""
+	if *css != "" {
+		b, err := os.ReadFile(*css)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "css: %v\n", err)
+			os.Exit(1)
+		}
+		cssContent = string(b)
+	}
+
+	var t *markdown.Template
+
+	if *tmpl != "" {
+		b, err := os.ReadFile(*tmpl)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "template: %v\n", err)
+			os.Exit(1)
+		}
+
+		t, err = markdown.ParseTemplate("index", string(b))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "template: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	n, err := markdown.ParseNaming(*naming)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *title == "" {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		*title = filepath.Base(abs)
+	}
+
+	o := &Opt{
+		root:    filepath.Clean(root),
+		outdir:  filepath.Clean(*outdir),
+		title:   *title,
+		naming:  n,
+		verbose: *verbose,
+		md: markdown.New(
+			markdown.WithTemplate(t),
+			markdown.WithTextTemplate(*textTemplate),
+			markdown.WithLayouts(*layouts),
+			markdown.WithCSS(cssContent),
+			markdown.WithExtendCSS(*extendCSS),
+			markdown.WithStyles(stylesheets.Values()...),
+			markdown.WithScripts(scripts.Values()...),
+			markdown.WithNaming(n),
+			markdown.WithExtensions(strings.Split(*ext, ",")...),
+			markdown.WithRoot(root),
+			markdown.WithBaseURL(*baseURL),
+		),
+		walker: walk.New(
+			walk.WithInclude(include.Values()...),
+			walk.WithExclude(exclude.Values()...),
+			walk.WithExtensions(strings.Split(*ext, ",")...),
+			walk.WithGitIgnore(*gitignore),
+		),
+	}
+
+	if err := o.build(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
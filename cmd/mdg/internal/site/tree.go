@@ -0,0 +1,244 @@
+package site
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.iscode.ca/mdg/pkg/format"
+	"go.iscode.ca/mdg/pkg/markdown"
+)
+
+// page is a document in the site. Generated pages are directory
+// indexes without a markdown source.
+type page struct {
+	src   string // path of the markdown document
+	out   string // path of the HTML document relative to the output directory
+	title string
+	date  time.Time
+}
+
+// dir is a directory in the source tree.
+type dir struct {
+	title string
+	index *page
+	pages []*page
+	dirs  []*dir
+}
+
+// each calls fn for the index and documents of the directory tree in
+// navigation order.
+func (d *dir) each(fn func(*page)) {
+	fn(d.index)
+
+	for _, p := range d.pages {
+		fn(p)
+	}
+
+	for _, sub := range d.dirs {
+		sub.each(fn)
+	}
+}
+
+// scan reads the source directory with the walker. Files other than
+// markdown documents are returned as assets.
+func (o *Opt) scan() (*dir, []string, error) {
+	root := &dir{title: o.title}
+	dirs := map[string]*dir{".": root}
+
+	var assets []string
+
+	err := o.walker.WalkFiles(o.root, func(file string, markdown bool) error {
+		if o.excluded(file) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(o.root, file)
+		if err != nil {
+			return err
+		}
+
+		if !markdown {
+			assets = append(assets, rel)
+			return nil
+		}
+
+		p, err := o.page(file, rel)
+		if err != nil {
+			return err
+		}
+
+		d := tree(dirs, filepath.Dir(rel))
+
+		name := filepath.Base(rel)
+
+		if filepath.Base(p.out) == "index.html" && filepath.Dir(p.out) == filepath.Dir(rel) && d.index == nil {
+			if p.title == strings.TrimSuffix(name, filepath.Ext(name)) {
+				p.title = d.title
+			}
+			d.index = p
+			return nil
+		}
+
+		d.pages = append(d.pages, p)
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(root.pages) == 0 && len(root.dirs) == 0 && root.index == nil {
+		return nil, assets, nil
+	}
+
+	for rel, d := range dirs {
+		if d.index == nil {
+			d.index = &page{
+				out:   filepath.Join(rel, "index.html"),
+				title: d.title,
+			}
+		}
+
+		slices.SortStableFunc(d.pages, comparePages)
+	}
+
+	return root, assets, nil
+}
+
+// tree returns the directory of the tree, adding it and its parents if
+// needed. Directories are added in walk order.
+func tree(dirs map[string]*dir, rel string) *dir {
+	if d, ok := dirs[rel]; ok {
+		return d
+	}
+
+	parent := tree(dirs, filepath.Dir(rel))
+
+	d := &dir{title: filepath.Base(rel)}
+	parent.dirs = append(parent.dirs, d)
+	dirs[rel] = d
+
+	return d
+}
+
+// excluded reports whether the file is in the output directory.
+func (o *Opt) excluded(file string) bool {
+	a, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+
+	b, err := filepath.Abs(o.outdir)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(b, a)
+
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// page reads the front matter of a markdown document.
+func (o *Opt) page(file, rel string) (*page, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	md, err := format.Parse(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	title := format.String("title", md.FrontMatter)
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+
+	return &page{
+		src:   file,
+		out:   o.naming.Path(rel),
+		title: title,
+		date:  date(md.FrontMatter),
+	}, nil
+}
+
+// date returns the front matter date. Documents without a valid date
+// are undated.
+func date(fm map[string]any) time.Time {
+	v, ok := fm["date"]
+	if !ok || v == nil || v == "" {
+		return time.Time{}
+	}
+
+	t, err := markdown.ParseDate(v)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
+
+// comparePages orders documents by date, newest first, followed by
+// undated documents sorted by title.
+func comparePages(a, b *page) int {
+	switch {
+	case !a.date.IsZero() && b.date.IsZero():
+		return -1
+	case a.date.IsZero() && !b.date.IsZero():
+		return 1
+	case !a.date.Equal(b.date):
+		return b.date.Compare(a.date)
+	}
+
+	if c := strings.Compare(strings.ToLower(a.title), strings.ToLower(b.title)); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a.out, b.out)
+}
+
+// url returns the relative URL from one page to another.
+func url(from, to *page) string {
+	rel, err := filepath.Rel(filepath.Dir(from.out), to.out)
+	if err != nil {
+		return filepath.ToSlash(to.out)
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// copyAsset copies a file from the source tree to the output directory.
+func (o *Opt) copyAsset(rel string) error {
+	src := filepath.Join(o.root, rel)
+	dst := filepath.Join(o.outdir, rel)
+
+	st, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if ost, err := os.Stat(dst); err == nil && !st.ModTime().After(ost.ModTime()) {
+		return nil
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(dst, b, 0644)
+}
@@ -0,0 +1,205 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"go.iscode.ca/mdg/internal/pkg/walk"
+	"go.iscode.ca/mdg/pkg/markdown"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+func TestComparePages(t *testing.T) {
+	for _, v := range []struct {
+		a, b     page
+		expected int
+	}{
+		{page{date: day("2024-01-02")}, page{date: day("2024-01-01")}, -1},
+		{page{date: day("2024-01-01")}, page{date: day("2024-01-02")}, 1},
+		{page{date: day("2024-01-01")}, page{title: "a"}, -1},
+		{page{title: "a"}, page{date: day("2024-01-01")}, 1},
+		{page{title: "a"}, page{title: "B"}, -1},
+		{page{title: "b"}, page{title: "A"}, 1},
+		{page{title: "a", out: "a.html"}, page{title: "A", out: "b.html"}, -1},
+		{page{title: "a", out: "a.html", date: day("2024-01-01")}, page{title: "a", out: "a.html", date: day("2024-01-01")}, 0},
+	} {
+		if got := comparePages(&v.a, &v.b); got != v.expected {
+			t.Errorf("%+v, %+v: expected %d, got %d", v.a, v.b, v.expected, got)
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	for _, v := range []struct {
+		from, to string
+		expected string
+	}{
+		{"index.html", "a.html", "a.html"},
+		{"index.html", "sub/index.html", "sub/index.html"},
+		{"sub/index.html", "index.html", "../index.html"},
+		{"sub/a/index.html", "sub/b/index.html", "../b/index.html"},
+		{"a.html", "a.html", "a.html"},
+	} {
+		if got := url(&page{out: v.from}, &page{out: v.to}); got != v.expected {
+			t.Errorf("%s -> %s: expected %s, got %s", v.from, v.to, v.expected, got)
+		}
+	}
+}
+
+func TestDate(t *testing.T) {
+	for _, v := range []struct {
+		name        string
+		frontMatter string
+		expected    time.Time
+	}{
+		{"yaml", "---\ndate: 2024-01-02\n---\n", day("2024-01-02")},
+		{"yaml string", "---\ndate: \"2024-01-02\"\n---\n", day("2024-01-02")},
+		{"toml local date", "+++\ndate = 2024-01-02\n+++\n", day("2024-01-02")},
+		{"toml local date-time", "+++\ndate = 2024-01-02T10:00:00\n+++\n", time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{"invalid", "---\ndate: soon\n---\n", time.Time{}},
+		{"undated", "---\ntitle: a\n---\n", time.Time{}},
+	} {
+		root := t.TempDir()
+		file := filepath.Join(root, "a.md")
+
+		if err := os.WriteFile(file, []byte(v.frontMatter+"# A\n"), 0o644); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		o := &Opt{naming: markdown.NamingIndex}
+
+		p, err := o.page(file, "a.md")
+		if err != nil {
+			t.Errorf("%s: %v", v.name, err)
+			continue
+		}
+
+		if !p.date.Equal(v.expected) {
+			t.Errorf("%s: expected %v, got %v", v.name, v.expected, p.date)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+
+	for name, content := range map[string]string{
+		"README.md":         "# Home\n",
+		"old.md":            "---\ntitle: Old\ndate: 2023-01-01\n---\n",
+		"new.md":            "+++\ntitle = \"New\"\ndate = 2024-01-01\n+++\n",
+		"about.md":          "---\ntitle: About\n---\n",
+		"img/logo.png":      "",
+		"guide/index.md":    "---\ntitle: Guide\n---\n",
+		"guide/install.md":  "",
+		"notes/a/b.md":      "",
+		"_drafts/draft.md":  "",
+		"public/stale.html": "",
+	} {
+		file := filepath.Join(root, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+
+	o := &Opt{
+		root:   root,
+		outdir: filepath.Join(root, "public"),
+		title:  "Site",
+		naming: markdown.NamingIndex,
+		walker: walk.New(),
+	}
+
+	tree, assets, err := o.scan()
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if expected := []string{filepath.Join("img", "logo.png")}; !slices.Equal(assets, expected) {
+		t.Errorf("expected assets %v, got %v", expected, assets)
+	}
+
+	var pages []string
+
+	tree.each(func(p *page) {
+		src := ""
+		if p.src != "" {
+			src, _ = filepath.Rel(root, p.src)
+		}
+		pages = append(pages, filepath.ToSlash(p.out)+" "+filepath.ToSlash(src)+" "+p.title)
+	})
+
+	// The README and index documents are promoted to the index of their
+	// directory. Directories without an index get a generated index.
+	expected := []string{
+		"index.html README.md Site",
+		"new.html new.md New",
+		"old.html old.md Old",
+		"about.html about.md About",
+		"guide/index.html guide/index.md Guide",
+		"guide/install.html guide/install.md install",
+		"notes/index.html  notes",
+		"notes/a/index.html  a",
+		"notes/a/b.html notes/a/b.md b",
+	}
+
+	if !slices.Equal(pages, expected) {
+		t.Errorf("expected pages:\n%q\ngot:\n%q", expected, pages)
+	}
+
+	empty := t.TempDir()
+
+	o.root = empty
+
+	tree, _, err = o.scan()
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if tree != nil {
+		t.Errorf("expected no tree for a directory without documents")
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	root := t.TempDir()
+
+	t.Chdir(root)
+
+	for _, v := range []struct {
+		outdir   string
+		file     string
+		expected bool
+	}{
+		{"public", "public/index.html", true},
+		{"public", "public", true},
+		{"public", "public/a/b.png", true},
+		{"public", "publication/a.md", false},
+		{"public", "docs/a.md", false},
+		{"./public/", "public/a.md", true},
+		{filepath.Join(root, "public"), "public/a.md", true},
+		{"docs/public", "docs/a.md", false},
+	} {
+		o := &Opt{outdir: v.outdir}
+
+		if got := o.excluded(filepath.FromSlash(v.file)); got != v.expected {
+			t.Errorf("%s in %s: expected %v, got %v", v.file, v.outdir, v.expected, got)
+		}
+	}
+}
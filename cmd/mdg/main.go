@@ -6,8 +6,11 @@ import (
 	"os"
 	"path"
 
+	configcmd "go.iscode.ca/mdg/cmd/mdg/internal/config"
 	"go.iscode.ca/mdg/cmd/mdg/internal/convert"
 	"go.iscode.ca/mdg/cmd/mdg/internal/format"
+	"go.iscode.ca/mdg/cmd/mdg/internal/serve"
+	"go.iscode.ca/mdg/cmd/mdg/internal/site"
 	"go.iscode.ca/mdg/pkg/config"
 )
 
@@ -21,8 +24,11 @@ Convert and format markdown.
 
 Commands:
 
+      config   - display the configuration
       convert  - convert markdown to HTML
       fmt      - format markdown
+      serve    - serve markdown as HTML with live reload
+      site     - build a static site from markdown
       version  - display version
 
 `, path.Base(os.Args[0]), config.Version(), os.Args[0])
@@ -47,10 +53,16 @@ func main() {
 	os.Args = append(os.Args[:1], args...)
 
 	switch command {
+	case "config":
+		configcmd.Run()
 	case "convert":
 		convert.Run()
 	case "fmt", "format":
 		format.Run()
+	case "serve":
+		serve.Run()
+	case "site":
+		site.Run()
 	case "help":
 		usage()
 	case "version":
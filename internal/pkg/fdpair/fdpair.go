@@ -7,6 +7,17 @@ import (
 type FD interface {
 	Open() error
 	Close() error
+	// Abort closes the output, discarding it if possible.
+	Abort() error
 	In() io.Reader
 	Out() io.Writer
 }
+
+// Name returns the name of the file read or written, if any.
+func Name(v any) string {
+	if f, ok := v.(interface{ Name() string }); ok {
+		return f.Name()
+	}
+
+	return ""
+}
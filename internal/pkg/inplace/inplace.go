@@ -0,0 +1,244 @@
+// Package inplace replaces the content of files safely: the new content
+// is written to a temporary file renamed over the original file.
+package inplace
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// ErrModified is returned when the file was modified after it was read.
+var ErrModified = errors.New("file modified since it was read")
+
+// File is the new content of a file. The content replaces the file
+// when the file is committed.
+type File struct {
+	*os.File
+
+	// name is the path of the replaced file. Symbolic links are
+	// resolved: their target is replaced.
+	name   string
+	source fs.FileInfo
+	copy   bool
+}
+
+type Option func(*File)
+
+// WithSource aborts the commit if the file does not match the file
+// information, obtained before reading the file.
+func WithSource(st fs.FileInfo) Option {
+	return func(f *File) {
+		f.source = st
+	}
+}
+
+// WithCopy enables or disables copying the content in place instead of
+// renaming the temporary file. Copying preserves hard links, ownership
+// and extended attributes but is not atomic. Hard linked files and
+// files which ownership cannot be preserved are always copied.
+func WithCopy(t bool) Option {
+	return func(f *File) {
+		f.copy = t
+	}
+}
+
+// Create returns a temporary file replacing the file when committed.
+// The temporary file has the mode and the ownership of the file. If the
+// file does not exist, it is created when committed.
+func Create(name string, opt ...Option) (*File, error) {
+	f := &File{
+		name: name,
+	}
+
+	for _, fn := range opt {
+		fn(f)
+	}
+
+	target, err := filepath.EvalSymlinks(name)
+	switch {
+	case err == nil:
+	case errors.Is(err, fs.ErrNotExist) && f.source == nil:
+		return f, f.create(name)
+	default:
+		return nil, err
+	}
+
+	st, err := os.Stat(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if links(st) > 1 {
+		f.copy = true
+	}
+
+	if err := f.create(target); err != nil {
+		return nil, err
+	}
+
+	if err := f.Chmod(st.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)); err != nil {
+		return nil, errors.Join(err, f.Abort())
+	}
+
+	// Only privileged users may give files away: the content is copied
+	// to keep the owner.
+	if err := chown(f.File, st); err != nil {
+		f.copy = true
+	}
+
+	return f, nil
+}
+
+// create creates the temporary file in the directory of the file. The
+// temporary file is hidden so it is ignored by the watcher. New files
+// are readable by everyone, subject to the umask.
+func (f *File) create(name string) error {
+	prefix := filepath.Join(filepath.Dir(name), "."+filepath.Base(name)+".")
+
+	for {
+		tmp, err := os.OpenFile(prefix+strconv.FormatUint(uint64(rand.Uint32()), 10), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		f.File = tmp
+		f.name = name
+
+		mu.Lock()
+		files[f] = struct{}{}
+		mu.Unlock()
+
+		return nil
+	}
+}
+
+// Name returns the name of the file replaced.
+func (f *File) Name() string {
+	return f.name
+}
+
+// Commit replaces the file with the content written. The temporary
+// file is removed.
+func (f *File) Commit() error {
+	if err := f.Sync(); err != nil {
+		return errors.Join(err, f.Abort())
+	}
+
+	if f.source != nil {
+		st, err := os.Stat(f.name)
+		if err != nil {
+			return errors.Join(err, f.Abort())
+		}
+
+		if !os.SameFile(st, f.source) || st.Size() != f.source.Size() || !st.ModTime().Equal(f.source.ModTime()) {
+			return errors.Join(fmt.Errorf("%s: %w", f.name, ErrModified), f.Abort())
+		}
+	}
+
+	// The file is replaced before the process exits on a signal.
+	mu.Lock()
+	defer mu.Unlock()
+
+	delete(files, f)
+
+	if f.copy {
+		return errors.Join(f.copyInPlace(), f.remove())
+	}
+
+	if err := f.File.Close(); err != nil {
+		return errors.Join(err, f.remove())
+	}
+
+	if err := os.Rename(f.File.Name(), f.name); err != nil {
+		return errors.Join(err, f.remove())
+	}
+
+	return nil
+}
+
+// copyInPlace copies the content of the temporary file to the file.
+func (f *File) copyInPlace() error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	w, err := os.OpenFile(f.name, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, f.File)
+	if err == nil {
+		err = w.Sync()
+	}
+
+	return errors.Join(err, w.Close())
+}
+
+// Abort removes the temporary file. The file is not modified.
+func (f *File) Abort() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	delete(files, f)
+
+	return f.remove()
+}
+
+// remove closes and removes the temporary file.
+func (f *File) remove() error {
+	err := f.File.Close()
+	if errors.Is(err, os.ErrClosed) {
+		err = nil
+	}
+
+	if rerr := os.Remove(f.File.Name()); rerr != nil && !errors.Is(rerr, fs.ErrNotExist) {
+		err = errors.Join(err, rerr)
+	}
+
+	return err
+}
+
+// files are the temporary files not committed or aborted.
+var (
+	mu    sync.Mutex
+	files = make(map[*File]struct{})
+)
+
+// RemoveOnSignal removes the temporary files and exits when the process
+// receives SIGINT or SIGTERM. The exit status is 128 plus the signal
+// number.
+func RemoveOnSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-c
+
+		// Files are not committed once the lock is held.
+		mu.Lock()
+
+		for f := range files {
+			_ = f.remove()
+		}
+
+		status := 1
+		if s, ok := sig.(syscall.Signal); ok {
+			status = 128 + int(s)
+		}
+
+		os.Exit(status)
+	}()
+}
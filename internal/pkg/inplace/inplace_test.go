@@ -0,0 +1,201 @@
+package inplace_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.iscode.ca/mdg/internal/pkg/inplace"
+)
+
+// replace replaces the content of the file.
+func replace(name, content string, opt ...inplace.Option) error {
+	f, err := inplace.Create(name, opt...)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(content); err != nil {
+		return errors.Join(err, f.Abort())
+	}
+
+	return f.Commit()
+}
+
+// check reports an error if the file does not contain the content.
+func check(t *testing.T, name, expected string) {
+	t.Helper()
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if string(b) != expected {
+		t.Errorf("%s: expected %q, got %q", name, expected, b)
+	}
+}
+
+func TestMode(t *testing.T) {
+	for _, mode := range []fs.FileMode{0o644, 0o600, 0o755} {
+		name := filepath.Join(t.TempDir(), "a.md")
+
+		if err := os.WriteFile(name, []byte("a"), mode); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		// The mode of the created file is subject to the umask.
+		if err := os.Chmod(name, mode); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		if err := replace(name, "b"); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		check(t, name, "b")
+
+		st, err := os.Stat(name)
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		if st.Mode() != mode {
+			t.Errorf("expected mode %v, got %v", mode, st.Mode())
+		}
+	}
+}
+
+func TestSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.md")
+	link := filepath.Join(dir, "link.md")
+
+	if err := os.WriteFile(target, []byte("a"), 0o644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := os.Symlink("target.md", link); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := replace(link, "b"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	st, err := os.Lstat(link)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if st.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("%s: expected symbolic link, got mode %v", link, st.Mode())
+	}
+
+	check(t, target, "b")
+}
+
+func TestHardLink(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.md")
+	b := filepath.Join(dir, "b.md")
+
+	if err := os.WriteFile(a, []byte("a"), 0o644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := os.Link(a, b); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := replace(a, "b"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	check(t, b, "b")
+
+	sta, err := os.Stat(a)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	stb, err := os.Stat(b)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if !os.SameFile(sta, stb) {
+		t.Errorf("%s and %s: expected hard links", a, b)
+	}
+}
+
+func TestModified(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.md")
+
+	if err := os.WriteFile(name, []byte("a"), 0o644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	st, err := os.Stat(name)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	f, err := inplace.Create(name, inplace.WithSource(st))
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if _, err := f.WriteString("b"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// The file is modified between the read and the commit.
+	if err := os.WriteFile(name, []byte("cc"), 0o644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := os.Chtimes(name, time.Time{}, st.ModTime().Add(time.Second)); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := f.Commit(); !errors.Is(err, inplace.ErrModified) {
+		t.Errorf("expected %v, got %v", inplace.ErrModified, err)
+	}
+
+	check(t, name, "cc")
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("expected the temporary file to be removed, got %d files", len(entries))
+	}
+}
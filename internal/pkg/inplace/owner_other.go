@@ -0,0 +1,18 @@
+//go:build !unix
+
+package inplace
+
+import (
+	"io/fs"
+	"os"
+)
+
+// chown does nothing: file ownership is not supported.
+func chown(*os.File, fs.FileInfo) error {
+	return nil
+}
+
+// links returns 1: hard links are not detected.
+func links(fs.FileInfo) uint64 {
+	return 1
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package inplace
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// chown sets the owner and the group of the file to the owner and the
+// group of the file information.
+func chown(f *os.File, st fs.FileInfo) error {
+	s, ok := st.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	if cur, err := f.Stat(); err == nil {
+		if c, ok := cur.Sys().(*syscall.Stat_t); ok && c.Uid == s.Uid && c.Gid == s.Gid {
+			return nil
+		}
+	}
+
+	return f.Chown(int(s.Uid), int(s.Gid))
+}
+
+// links returns the number of hard links of the file.
+func links(st fs.FileInfo) uint64 {
+	if s, ok := st.Sys().(*syscall.Stat_t); ok {
+		return uint64(s.Nlink)
+	}
+
+	return 1
+}
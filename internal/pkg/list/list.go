@@ -0,0 +1,25 @@
+// Package list implements repeatable command line options.
+package list
+
+import "strings"
+
+// Strings is a command line option collecting values. The option may be
+// repeated.
+type Strings struct {
+	values []string
+}
+
+// Values returns the values of the option.
+func (l *Strings) Values() []string {
+	return l.values
+}
+
+func (l *Strings) String() string {
+	return strings.Join(l.values, ",")
+}
+
+func (l *Strings) Set(s string) error {
+	l.values = append(l.values, s)
+
+	return nil
+}
@@ -0,0 +1,113 @@
+// Package pool processes files concurrently with a bounded number of
+// workers.
+package pool
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Jobs returns the number of workers: n or the number of CPUs if n is
+// less than 1.
+func Jobs(n int) int {
+	if n < 1 {
+		return runtime.NumCPU()
+	}
+
+	return n
+}
+
+// Run calls fn for each file using n workers. The output and the
+// messages written by fn are copied to stdout and stderr in the order of
+// the files. Run returns the error of the first file which failed, in
+// the order of the files, after writing the output of the previous
+// files. Files are not started after an error.
+func Run(stdout, stderr io.Writer, n int, files []string, fn func(file string, stdout, stderr io.Writer) error) error {
+	return run(stdout, stderr, n, files, fn, false)
+}
+
+// RunAll calls fn for each file using n workers, like Run, but does not
+// stop after an error. RunAll returns the errors of the files joined in
+// the order of the files.
+func RunAll(stdout, stderr io.Writer, n int, files []string, fn func(file string, stdout, stderr io.Writer) error) error {
+	return run(stdout, stderr, n, files, fn, true)
+}
+
+func run(stdout, stderr io.Writer, n int, files []string, fn func(file string, stdout, stderr io.Writer) error, keepGoing bool) error {
+	type result struct {
+		out  bytes.Buffer
+		msg  bytes.Buffer
+		err  error
+		done chan struct{}
+	}
+
+	results := make([]*result, len(files))
+	for i := range results {
+		results[i] = &result{done: make(chan struct{})}
+	}
+
+	jobs := make(chan int)
+
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+
+	for range min(Jobs(n), len(files)) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				r := results[i]
+
+				// Files are started in order: files skipped after an
+				// error follow the file which failed.
+				if !failed.Load() {
+					if r.err = fn(files[i], &r.out, &r.msg); r.err != nil && !keepGoing {
+						failed.Store(true)
+					}
+				}
+
+				close(r.done)
+			}
+		}()
+	}
+
+	go func() {
+		for i := range files {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	defer wg.Wait()
+
+	var errs []error
+
+	for _, r := range results {
+		<-r.done
+
+		_, err := stderr.Write(r.msg.Bytes())
+		if err == nil {
+			_, err = stdout.Write(r.out.Bytes())
+		}
+
+		if err != nil {
+			failed.Store(true)
+			return errors.Join(append(errs, err)...)
+		}
+
+		if r.err != nil {
+			if !keepGoing {
+				return r.err
+			}
+			errs = append(errs, r.err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,122 @@
+package pool_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.iscode.ca/mdg/internal/pkg/pool"
+)
+
+func files(n int) []string {
+	files := make([]string, n)
+	for i := range files {
+		files[i] = fmt.Sprintf("%02d.md", i)
+	}
+
+	return files
+}
+
+func TestRunOrder(t *testing.T) {
+	in := files(20)
+
+	var stdout, stderr bytes.Buffer
+
+	err := pool.Run(&stdout, &stderr, 4, in, func(file string, w, msg io.Writer) error {
+		i, err := strconv.Atoi(file[:2])
+		if err != nil {
+			return err
+		}
+
+		// Later files finish first.
+		time.Sleep(time.Duration(len(in)-i) * time.Millisecond)
+
+		fmt.Fprintln(msg, "start", file)
+		fmt.Fprintln(w, file)
+		fmt.Fprintln(msg, "end", file)
+
+		return nil
+	})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	var out, msg strings.Builder
+	for _, file := range in {
+		fmt.Fprintln(&out, file)
+		fmt.Fprintf(&msg, "start %s\nend %s\n", file, file)
+	}
+
+	if stdout.String() != out.String() {
+		t.Errorf("expected output:\n%s\ngot:\n%s", out.String(), stdout.String())
+	}
+
+	if stderr.String() != msg.String() {
+		t.Errorf("expected messages:\n%s\ngot:\n%s", msg.String(), stderr.String())
+	}
+}
+
+func TestRunError(t *testing.T) {
+	in := files(50)
+	failed := errors.New("failed")
+
+	var started atomic.Int64
+	var stdout bytes.Buffer
+
+	err := pool.Run(&stdout, io.Discard, 2, in, func(file string, w, _ io.Writer) error {
+		started.Add(1)
+
+		if file == "05.md" {
+			return failed
+		}
+
+		time.Sleep(time.Millisecond)
+
+		fmt.Fprintln(w, file)
+
+		return nil
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("expected %v, got %v", failed, err)
+	}
+
+	if expected := "00.md\n01.md\n02.md\n03.md\n04.md\n"; stdout.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, stdout.String())
+	}
+
+	// The workers started before the error may process one more file
+	// each.
+	if n := started.Load(); n > 8 {
+		t.Errorf("expected processing to stop after the error, %d files started", n)
+	}
+}
+
+func TestRunAll(t *testing.T) {
+	in := files(10)
+
+	var stdout bytes.Buffer
+
+	err := pool.RunAll(&stdout, io.Discard, 3, in, func(file string, w, _ io.Writer) error {
+		fmt.Fprintln(w, file)
+
+		if file == "02.md" || file == "07.md" {
+			return errors.New(file)
+		}
+
+		return nil
+	})
+	if err == nil || err.Error() != "02.md\n07.md" {
+		t.Errorf("expected errors of 02.md and 07.md, got %v", err)
+	}
+
+	if strings.Count(stdout.String(), "\n") != len(in) {
+		t.Errorf("expected the output of all files, got:\n%s", stdout.String())
+	}
+}
@@ -0,0 +1,161 @@
+// Package report records the outcome of processing each file.
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// File outcomes.
+const (
+	Converted   = "converted"
+	Formatted   = "formatted"
+	Unformatted = "unformatted"
+	Idempotent  = "idempotent"
+	Skipped     = "skipped"
+	Failed      = "failed"
+)
+
+// Report formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Result is the outcome of processing a file.
+type Result struct {
+	File   string `json:"file"`
+	Output string `json:"output,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report collects the results of a command. A nil report ignores
+// results. Report is safe for concurrent use.
+type Report struct {
+	command  string
+	statuses []string
+
+	mu      sync.Mutex
+	results []Result
+}
+
+// New returns a report of the command. The summary counts the files of
+// each status, in order, including statuses without files.
+func New(command string, statuses ...string) *Report {
+	return &Report{
+		command:  command,
+		statuses: statuses,
+	}
+}
+
+// Add records the outcome of processing the file.
+func (r *Report) Add(file, output, status string, err error) {
+	if r == nil {
+		return
+	}
+
+	res := Result{
+		File:   file,
+		Output: output,
+		Status: status,
+	}
+
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.results = append(r.results, res)
+}
+
+// Results returns the results sorted by file.
+func (r *Report) Results() []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := make([]Result, len(r.results))
+	copy(results, r.results)
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].File < results[j].File
+	})
+
+	return results
+}
+
+// Count returns the number of files of each status.
+func (r *Report) Count() map[string]int {
+	count := make(map[string]int, len(r.statuses))
+	for _, v := range r.statuses {
+		count[v] = 0
+	}
+
+	for _, v := range r.Results() {
+		count[v.Status]++
+	}
+
+	return count
+}
+
+// Failed reports whether a file failed.
+func (r *Report) Failed() bool {
+	return r.Count()[Failed] > 0
+}
+
+// Summary returns the number of files of each status:
+//
+//	convert: 3 converted, 1 skipped, 0 failed
+func (r *Report) Summary() string {
+	count := r.Count()
+
+	s := make([]string, 0, len(r.statuses))
+	for _, v := range r.statuses {
+		s = append(s, fmt.Sprintf("%d %s", count[v], v))
+	}
+
+	return r.command + ": " + strings.Join(s, ", ")
+}
+
+// Write writes the report in the format: a summary or a JSON document
+// containing the result of each file and the summary.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatText:
+		_, err := fmt.Fprintln(w, r.Summary())
+		return err
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(struct {
+			Command string         `json:"command"`
+			Files   []Result       `json:"files"`
+			Summary map[string]int `json:"summary"`
+		}{
+			Command: r.command,
+			Files:   r.Results(),
+			Summary: r.Count(),
+		})
+	default:
+		return fmt.Errorf("invalid report format: %s", format)
+	}
+}
+
+// WriteFile writes the report in the format to the file.
+func (r *Report) WriteFile(name, format string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(r.Write(f, format), f.Close())
+}
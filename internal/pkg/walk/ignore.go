@@ -0,0 +1,112 @@
+package walk
+
+import (
+	"path"
+	"strings"
+)
+
+// rule is a glob pattern using the .gitignore syntax:
+//
+//   - a pattern without a slash matches a file or directory name at any
+//     depth
+//   - a pattern containing a slash matches a path relative to the
+//     directory of the ignore file
+//   - ** matches any number of directories
+//   - a trailing slash only matches directories
+//   - a leading ! re-includes a file
+type rule struct {
+	base     string
+	pattern  string
+	anchored bool
+	dirOnly  bool
+	negate   bool
+}
+
+// parseIgnore parses the rules of an ignore file of the directory.
+func parseIgnore(s, base string) []rule {
+	var rules []rule
+
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rules = append(rules, parseRule(line, base))
+	}
+
+	return rules
+}
+
+func parseRule(p, base string) rule {
+	r := rule{base: base}
+
+	if strings.HasPrefix(p, "!") {
+		r.negate = true
+		p = p[1:]
+	} else if strings.HasPrefix(p, `\`) {
+		p = p[1:]
+	}
+
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+
+	if strings.Contains(p, "/") {
+		r.anchored = true
+		p = strings.TrimPrefix(p, "/")
+	}
+
+	r.pattern = p
+
+	return r
+}
+
+// match reports whether the path relative to the walked root matches.
+func (r rule) match(rel string, dir bool) bool {
+	if r.dirOnly && !dir {
+		return false
+	}
+
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+
+	if !r.anchored {
+		ok, _ := path.Match(r.pattern, path.Base(rel))
+		return ok
+	}
+
+	return glob(strings.Split(r.pattern, "/"), strings.Split(rel, "/"))
+}
+
+// glob matches the path components. ** matches zero or more
+// components.
+func glob(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if glob(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
@@ -0,0 +1,292 @@
+// Package walk finds markdown documents in directory trees.
+package walk
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile lists patterns of files and directories ignored by the
+// walker, using the .gitignore syntax.
+const IgnoreFile = ".mdgignore"
+
+// Walker selects markdown documents in a directory tree. Files and
+// directories starting with a dot or an underscore are ignored.
+type Walker struct {
+	include   []string
+	exclude   []string
+	exts      []string
+	gitignore bool
+}
+
+type Option func(*Walker)
+
+// WithInclude restricts the documents to files matching one of the
+// patterns.
+func WithInclude(patterns ...string) Option {
+	return func(w *Walker) {
+		w.include = append(w.include, patterns...)
+	}
+}
+
+// WithExclude ignores files and directories matching one of the
+// patterns.
+func WithExclude(patterns ...string) Option {
+	return func(w *Walker) {
+		w.exclude = append(w.exclude, patterns...)
+	}
+}
+
+// WithExtensions sets the file extensions of markdown documents. The
+// default extensions are .md and .markdown.
+func WithExtensions(exts ...string) Option {
+	return func(w *Walker) {
+		w.exts = nil
+		for _, v := range exts {
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			if !strings.HasPrefix(v, ".") {
+				v = "." + v
+			}
+			w.exts = append(w.exts, v)
+		}
+	}
+}
+
+// WithGitIgnore enables or disables reading .gitignore files in addition
+// to .mdgignore files.
+func WithGitIgnore(t bool) Option {
+	return func(w *Walker) {
+		w.gitignore = t
+	}
+}
+
+// New configures the walker.
+func New(opt ...Option) *Walker {
+	w := &Walker{
+		exts: []string{".md", ".markdown"},
+	}
+
+	for _, fn := range opt {
+		fn(w)
+	}
+
+	return w
+}
+
+// IsMarkdown reports whether the file has a markdown extension.
+func (w *Walker) IsMarkdown(file string) bool {
+	ext := filepath.Ext(file)
+
+	for _, v := range w.exts {
+		if strings.EqualFold(ext, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Walk calls fn for each markdown document in the directory tree. The
+// rules are applied to the path relative to the root. If the root is a
+// file, fn is called if the file is a markdown document.
+func (w *Walker) Walk(root string, fn func(file string) error) error {
+	return w.WalkFiles(root, func(file string, markdown bool) error {
+		if !markdown {
+			return nil
+		}
+		return fn(file)
+	})
+}
+
+// WalkFiles calls fn for each file in the directory tree which is not
+// ignored or excluded, like Walk, including the files which are not
+// markdown documents, such as images. markdown reports whether the file
+// is a markdown document selected by the include patterns.
+func (w *Walker) WalkFiles(root string, fn func(file string, markdown bool) error) error {
+	st, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+
+	if !st.IsDir() {
+		return fn(root, w.IsMarkdown(root))
+	}
+
+	rules := make(map[string][]rule)
+
+	return filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+
+		if rel == "." {
+			rules[rel] = w.readIgnore(file, "")
+			return nil
+		}
+
+		parent := rules[path.Dir(rel)]
+
+		if w.ignored(rel, d.IsDir(), parent) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			rules[rel] = append(parent[:len(parent):len(parent)], w.readIgnore(file, rel)...)
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		return fn(file, w.selected(rel))
+	})
+}
+
+// Match reports whether the file is a markdown document selected in the
+// directory tree.
+func (w *Walker) Match(root, file string) bool {
+	rel, ok := w.visible(root, file, false)
+	if !ok {
+		return false
+	}
+
+	if rel == "." {
+		return w.IsMarkdown(file)
+	}
+
+	return w.selected(rel)
+}
+
+// Ignored reports whether the file or directory, or one of its parent
+// directories, is hidden, ignored or excluded in the directory tree.
+// Files outside of the tree are ignored.
+func (w *Walker) Ignored(root, file string) bool {
+	st, err := os.Stat(file)
+
+	_, ok := w.visible(root, file, err == nil && st.IsDir())
+
+	return !ok
+}
+
+// visible returns the path of the file relative to the root if neither
+// the file nor its parent directories are ignored.
+func (w *Walker) visible(root, file string, isDir bool) (string, bool) {
+	rel, err := filepath.Rel(root, file)
+	if err != nil {
+		return "", false
+	}
+
+	rel = filepath.ToSlash(rel)
+
+	if rel == "." {
+		return rel, true
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+
+	rules := w.readIgnore(root, "")
+	components := strings.Split(rel, "/")
+
+	for i := range components {
+		p := strings.Join(components[:i+1], "/")
+		dir := i < len(components)-1 || isDir
+
+		if w.ignored(p, dir, rules) {
+			return "", false
+		}
+
+		if dir {
+			rules = append(rules, w.readIgnore(filepath.Join(root, filepath.FromSlash(p)), p)...)
+		}
+	}
+
+	return rel, true
+}
+
+// ignored reports whether the path relative to the root is hidden,
+// ignored or excluded.
+func (w *Walker) ignored(rel string, dir bool, rules []rule) bool {
+	name := path.Base(rel)
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+		return true
+	}
+
+	ignored := false
+	for _, r := range rules {
+		if r.match(rel, dir) {
+			ignored = !r.negate
+		}
+	}
+
+	if ignored {
+		return true
+	}
+
+	for _, p := range w.exclude {
+		if parseRule(p, "").match(rel, dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selected reports whether the file relative to the root is a markdown
+// document matching the include patterns.
+func (w *Walker) selected(rel string) bool {
+	if !w.IsMarkdown(rel) {
+		return false
+	}
+
+	if len(w.include) == 0 {
+		return true
+	}
+
+	for _, p := range w.include {
+		if parseRule(p, "").match(rel, false) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readIgnore returns the rules of the ignore files of the directory.
+func (w *Walker) readIgnore(dir, rel string) []rule {
+	// .mdgignore rules override .gitignore rules.
+	files := []string{IgnoreFile}
+	if w.gitignore {
+		files = []string{".gitignore", IgnoreFile}
+	}
+
+	var rules []rule
+
+	for _, name := range files {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+
+		rules = append(rules, parseIgnore(string(b), rel)...)
+	}
+
+	return rules
+}
@@ -0,0 +1,256 @@
+package walk_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"go.iscode.ca/mdg/internal/pkg/walk"
+)
+
+// tree creates the files in the directory.
+func tree(root string, files map[string]string) error {
+	for name, content := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walkRel returns the documents found in the directory, relative to the
+// directory.
+func walkRel(w *walk.Walker, dir string) ([]string, error) {
+	var files []string
+
+	err := w.Walk(dir, func(file string) error {
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, filepath.ToSlash(rel))
+
+		return nil
+	})
+
+	return files, err
+}
+
+var files = map[string]string{
+	"docs/a.md":                "",
+	"docs/b.markdown":          "",
+	"docs/c.txt":               "",
+	"docs/_drafts/x.md":        "",
+	"docs/.git/x.md":           "",
+	"docs/.hidden.md":          "",
+	"docs/sub/d.md":            "",
+	"docs/sub/keep.md":         "",
+	"docs/sub/deep/e.md":       "",
+	"docs/build/f.md":          "",
+	"docs/vendor/g.md":         "",
+	"docs/sub/vendor.md":       "",
+	"docs/.mdgignore":          "build/\nsub/*.md\n!sub/keep.md\n",
+	"docs/sub/deep/.mdgignore": "e.md\n",
+	"docs/.gitignore":          "vendor\n",
+}
+
+func TestWalk(t *testing.T) {
+	root := t.TempDir()
+
+	if err := tree(root, files); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	for _, v := range []struct {
+		name     string
+		opt      []walk.Option
+		expected []string
+	}{
+		{
+			name:     "ignore files",
+			expected: []string{"a.md", "b.markdown", "sub/keep.md", "vendor/g.md"},
+		},
+		{
+			name:     "gitignore",
+			opt:      []walk.Option{walk.WithGitIgnore(true)},
+			expected: []string{"a.md", "b.markdown", "sub/keep.md"},
+		},
+		{
+			name:     "exclude directory",
+			opt:      []walk.Option{walk.WithExclude("vendor/")},
+			expected: []string{"a.md", "b.markdown", "sub/keep.md"},
+		},
+		{
+			name:     "include",
+			opt:      []walk.Option{walk.WithInclude("**/keep.md", "a.*")},
+			expected: []string{"a.md", "sub/keep.md"},
+		},
+		{
+			name:     "extensions",
+			opt:      []walk.Option{walk.WithExtensions("txt", ".md")},
+			expected: []string{"a.md", "c.txt", "sub/keep.md", "vendor/g.md"},
+		},
+	} {
+		got, err := walkRel(walk.New(v.opt...), filepath.Join(root, "docs"))
+		if err != nil {
+			t.Errorf("%s: %v", v.name, err)
+			continue
+		}
+
+		if !slices.Equal(got, v.expected) {
+			t.Errorf("%s: expected %v, got %v", v.name, v.expected, got)
+		}
+	}
+}
+
+func TestWalkParent(t *testing.T) {
+	root := t.TempDir()
+
+	if err := tree(root, files); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := os.Mkdir(filepath.Join(root, "work"), 0o755); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// The rules apply to the path relative to the walked directory: ..
+	// is not a hidden directory.
+	t.Chdir(filepath.Join(root, "work"))
+
+	got, err := walkRel(walk.New(), "../docs")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if expected := []string{"a.md", "b.markdown", "sub/keep.md", "vendor/g.md"}; !slices.Equal(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestWalkFiles(t *testing.T) {
+	root := t.TempDir()
+
+	if err := tree(root, files); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	docs := filepath.Join(root, "docs")
+
+	var markdown, other []string
+
+	err := walk.New(walk.WithInclude("a.md")).WalkFiles(docs, func(file string, md bool) error {
+		rel, err := filepath.Rel(docs, file)
+		if err != nil {
+			return err
+		}
+
+		if md {
+			markdown = append(markdown, filepath.ToSlash(rel))
+		} else {
+			other = append(other, filepath.ToSlash(rel))
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if expected := []string{"a.md"}; !slices.Equal(markdown, expected) {
+		t.Errorf("expected documents %v, got %v", expected, markdown)
+	}
+
+	if expected := []string{"b.markdown", "c.txt", "sub/keep.md", "vendor/g.md"}; !slices.Equal(other, expected) {
+		t.Errorf("expected other files %v, got %v", expected, other)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	root := t.TempDir()
+
+	if err := tree(root, files); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	docs := filepath.Join(root, "docs")
+
+	w := walk.New()
+
+	for _, v := range []struct {
+		file     string
+		expected bool
+	}{
+		{"a.md", true},
+		{"c.txt", false},
+		{"_drafts/x.md", false},
+		{".git/x.md", false},
+		{".hidden.md", false},
+		{"build/f.md", false},
+		{"sub/d.md", false},
+		{"sub/keep.md", true},
+		{"sub/deep/e.md", false},
+		{"../a.md", false},
+	} {
+		if got := w.Match(docs, filepath.Join(docs, filepath.FromSlash(v.file))); got != v.expected {
+			t.Errorf("%s: expected %v, got %v", v.file, v.expected, got)
+		}
+	}
+
+	if file := filepath.Join(docs, "a.md"); !w.Match(file, file) {
+		t.Errorf("%s: expected the walked file to match", file)
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	root := t.TempDir()
+
+	if err := tree(root, files); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	docs := filepath.Join(root, "docs")
+
+	w := walk.New(walk.WithExclude("*.txt"))
+
+	for _, v := range []struct {
+		file     string
+		expected bool
+	}{
+		{".", false},
+		{"a.md", false},
+		{"sub", false},
+		{"sub/keep.md", false},
+		{"vendor/g.md", false},
+		{"c.txt", true},
+		{".mdgignore", true},
+		{".git/x.md", true},
+		{"_drafts", true},
+		{"_drafts/x.md", true},
+		{"build", true},
+		{"build/f.md", true},
+		{"sub/d.md", true},
+		{"../a.md", true},
+	} {
+		if got := w.Ignored(docs, filepath.Join(docs, filepath.FromSlash(v.file))); got != v.expected {
+			t.Errorf("%s: expected %v, got %v", v.file, v.expected, got)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+//go:build linux
+
+package watch
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO |
+	syscall.IN_CREATE | syscall.IN_DELETE_SELF
+
+// inotify reports modified files using Linux filesystem notifications.
+type inotify struct {
+	*Watcher
+
+	fd int
+	f  *os.File
+
+	mu   sync.Mutex
+	dirs map[int32]string
+
+	// only are the directories watched for the files given as paths:
+	// the events of the other files are ignored.
+	only  map[int32]bool
+	files map[string]bool
+}
+
+func (w *Watcher) backend() (backend, error) {
+	if w.poll {
+		return newPoller(w), nil
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		// inotify is unavailable: fall back to polling
+		return newPoller(w), nil
+	}
+
+	in := &inotify{
+		Watcher: w,
+		fd:      fd,
+		f:       os.NewFile(uintptr(fd), "inotify"),
+		dirs:    make(map[int32]string),
+		only:    make(map[int32]bool),
+		files:   make(map[string]bool),
+	}
+
+	var werr error
+
+	for _, file := range w.paths {
+		if st, err := os.Stat(file); err == nil && !st.IsDir() {
+			werr = errors.Join(werr, in.addFile(file))
+		}
+	}
+
+	w.walk(func(file string, d fs.DirEntry) {
+		if d.IsDir() {
+			werr = errors.Join(werr, in.add(file))
+		}
+	})
+
+	if werr != nil {
+		_ = in.f.Close()
+		return nil, werr
+	}
+
+	return in, nil
+}
+
+func (in *inotify) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(in.fd, dir, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+
+	in.mu.Lock()
+	in.dirs[int32(wd)] = dir
+	delete(in.only, int32(wd))
+	in.mu.Unlock()
+
+	return nil
+}
+
+// addFile watches the directory of a file: files are often replaced
+// rather than modified. Only the events of the file are reported, unless
+// the directory is watched too.
+func (in *inotify) addFile(file string) error {
+	dir := filepath.Dir(file)
+
+	wd, err := syscall.InotifyAddWatch(in.fd, dir, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if _, ok := in.dirs[int32(wd)]; !ok {
+		in.dirs[int32(wd)] = dir
+		in.only[int32(wd)] = true
+	}
+
+	in.files[filepath.Join(dir, filepath.Base(file))] = true
+
+	return nil
+}
+
+func (in *inotify) run(ch chan<- string) {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+
+	for {
+		n, err := in.f.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+			off += syscall.SizeofInotifyEvent + int(ev.Len)
+
+			if !in.event(ch, ev, cstring(name)) {
+				return
+			}
+		}
+	}
+}
+
+// event reports the file of an event. It returns false if the watcher
+// is closed.
+func (in *inotify) event(ch chan<- string, ev *syscall.InotifyEvent, name string) bool {
+	if ev.Mask&syscall.IN_Q_OVERFLOW != 0 {
+		// Events were dropped: any file may have been modified.
+		return in.rescan(ch)
+	}
+
+	in.mu.Lock()
+	dir, ok := in.dirs[ev.Wd]
+	only := in.only[ev.Wd]
+	if ev.Mask&syscall.IN_IGNORED != 0 {
+		delete(in.dirs, ev.Wd)
+		delete(in.only, ev.Wd)
+	}
+	in.mu.Unlock()
+
+	if !ok || name == "" {
+		return true
+	}
+
+	file := filepath.Join(dir, name)
+
+	if only && !in.files[file] {
+		return true
+	}
+
+	if ev.Mask&syscall.IN_ISDIR != 0 {
+		if ev.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 && !hidden(file) {
+			in.watchNewDir(ch, file)
+		}
+		return true
+	}
+
+	if ev.Mask&syscall.IN_CREATE != 0 {
+		// wait for the file to be closed
+		return true
+	}
+
+	return in.send(ch, file)
+}
+
+// rescan watches the directories created since the watcher was started
+// and reports every file of the watched paths.
+func (in *inotify) rescan(ch chan<- string) bool {
+	ok := true
+
+	in.walk(func(file string, d fs.DirEntry) {
+		switch {
+		case !ok:
+		case d.IsDir():
+			_ = in.add(file)
+		case d.Type().IsRegular():
+			ok = in.send(ch, file)
+		}
+	})
+
+	return ok
+}
+
+// watchNewDir adds watches for a directory created after the watcher
+// was started and reports any files already written to it.
+func (in *inotify) watchNewDir(ch chan<- string, dir string) {
+	_ = filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if d.IsDir() {
+			if file != dir && hidden(file) {
+				return filepath.SkipDir
+			}
+			_ = in.add(file)
+			return nil
+		}
+
+		if d.Type().IsRegular() {
+			in.send(ch, file)
+		}
+
+		return nil
+	})
+}
+
+func (in *inotify) close() error {
+	return in.f.Close()
+}
+
+func cstring(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
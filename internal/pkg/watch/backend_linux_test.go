@@ -0,0 +1,83 @@
+//go:build linux
+
+package watch
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+)
+
+func TestOverflow(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), nil, 0o644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	w := &Watcher{
+		paths:  []string{dir},
+		filter: func(string) bool { return true },
+		done:   make(chan struct{}),
+	}
+
+	b, err := w.backend()
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	defer b.close()
+
+	in, ok := b.(*inotify)
+	if !ok {
+		t.Skip("inotify is unavailable")
+	}
+
+	// The events of the new directory and file are dropped.
+	sub := filepath.Join(dir, "sub")
+
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := os.WriteFile(filepath.Join(sub, "b.md"), nil, 0o644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	ch := make(chan string)
+	files := make(chan []string)
+
+	go func() {
+		var got []string
+		for file := range ch {
+			got = append(got, file)
+		}
+		files <- got
+	}()
+
+	if !in.event(ch, &syscall.InotifyEvent{Wd: -1, Mask: syscall.IN_Q_OVERFLOW}, "") {
+		t.Errorf("expected the watcher to run")
+	}
+
+	close(ch)
+
+	got := <-files
+
+	if expected := []string{filepath.Join(dir, "a.md"), filepath.Join(sub, "b.md")}; !slices.Equal(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if !slices.Contains(slices.Collect(maps.Values(in.dirs)), sub) {
+		t.Errorf("expected %s to be watched", sub)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package watch
+
+func (w *Watcher) backend() (backend, error) {
+	return newPoller(w), nil
+}
@@ -0,0 +1,79 @@
+package watch
+
+import (
+	"io/fs"
+	"time"
+)
+
+// poller periodically scans the directory trees and compares the
+// modification time and size of each file.
+type poller struct {
+	*Watcher
+
+	stop chan struct{}
+}
+
+type stat struct {
+	modtime time.Time
+	size    int64
+}
+
+func newPoller(w *Watcher) *poller {
+	return &poller{
+		Watcher: w,
+		stop:    make(chan struct{}),
+	}
+}
+
+func (p *poller) scan() map[string]stat {
+	m := make(map[string]stat)
+
+	p.walk(func(file string, d fs.DirEntry) {
+		if !d.Type().IsRegular() {
+			return
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return
+		}
+
+		m[file] = stat{modtime: fi.ModTime(), size: fi.Size()}
+	})
+
+	return m
+}
+
+func (p *poller) run(ch chan<- string) {
+	last := p.scan()
+
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-t.C:
+		}
+
+		cur := p.scan()
+
+		for file, st := range cur {
+			if prev, ok := last[file]; ok && prev == st {
+				continue
+			}
+
+			if !p.send(ch, file) {
+				return
+			}
+		}
+
+		last = cur
+	}
+}
+
+func (p *poller) close() error {
+	close(p.stop)
+	return nil
+}
@@ -0,0 +1,189 @@
+// Package watch reports modified files in a set of directory trees.
+package watch
+
+import (
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watcher sends batches of modified files. Bursts of modifications are
+// coalesced into a single batch.
+type Watcher struct {
+	paths    []string
+	delay    time.Duration
+	interval time.Duration
+	poll     bool
+	filter   func(string) bool
+
+	b      backend
+	events chan []string
+	done   chan struct{}
+	once   sync.Once
+}
+
+// backend reports the path of each modified file.
+type backend interface {
+	run(chan<- string)
+	close() error
+}
+
+type Option func(*Watcher)
+
+// WithDelay sets the period without modifications before a batch of
+// files is sent.
+func WithDelay(d time.Duration) Option {
+	return func(w *Watcher) {
+		if d > 0 {
+			w.delay = d
+		}
+	}
+}
+
+// WithInterval sets the interval between scans when polling.
+func WithInterval(d time.Duration) Option {
+	return func(w *Watcher) {
+		if d > 0 {
+			w.interval = d
+		}
+	}
+}
+
+// WithPolling forces scanning the directory trees for changes instead
+// of using filesystem notifications.
+func WithPolling(t bool) Option {
+	return func(w *Watcher) {
+		w.poll = t
+	}
+}
+
+// WithFilter sets a function selecting which files are reported.
+func WithFilter(fn func(string) bool) Option {
+	return func(w *Watcher) {
+		if fn != nil {
+			w.filter = fn
+		}
+	}
+}
+
+// New starts watching the directory trees and files. Hidden files and
+// directories are ignored.
+func New(paths []string, opt ...Option) (*Watcher, error) {
+	w := &Watcher{
+		paths:    paths,
+		delay:    100 * time.Millisecond,
+		interval: time.Second,
+		filter:   func(string) bool { return true },
+		events:   make(chan []string),
+		done:     make(chan struct{}),
+	}
+
+	for _, fn := range opt {
+		fn(w)
+	}
+
+	b, err := w.backend()
+	if err != nil {
+		return nil, err
+	}
+
+	w.b = b
+
+	ch := make(chan string)
+
+	go b.run(ch)
+	go w.debounce(ch)
+
+	return w, nil
+}
+
+// Events returns the channel of modified files. The files in each
+// batch are sorted.
+func (w *Watcher) Events() <-chan []string {
+	return w.events
+}
+
+// Close stops the watcher.
+func (w *Watcher) Close() error {
+	var err error
+
+	w.once.Do(func() {
+		close(w.done)
+		err = w.b.close()
+	})
+
+	return err
+}
+
+func (w *Watcher) debounce(ch <-chan string) {
+	defer close(w.events)
+
+	pending := make(map[string]struct{})
+
+	timer := time.NewTimer(w.delay)
+	timer.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case file := <-ch:
+			if hidden(file) || !w.filter(file) {
+				continue
+			}
+			pending[file] = struct{}{}
+			timer.Reset(w.delay)
+		case <-timer.C:
+			files := make([]string, 0, len(pending))
+			for file := range pending {
+				files = append(files, file)
+			}
+			slices.Sort(files)
+			clear(pending)
+
+			select {
+			case w.events <- files:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+func (w *Watcher) send(ch chan<- string, file string) bool {
+	select {
+	case ch <- file:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// hidden reports whether the file name starts with a dot. Temporary
+// files written while formatting in place are hidden.
+func hidden(file string) bool {
+	return strings.HasPrefix(filepath.Base(file), ".")
+}
+
+// walk calls fn for each directory and regular file below the watched
+// paths, skipping hidden directories.
+func (w *Watcher) walk(fn func(string, fs.DirEntry)) {
+	for _, root := range w.paths {
+		_ = filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+
+			if d.IsDir() && file != root && hidden(file) {
+				return filepath.SkipDir
+			}
+
+			fn(file, d)
+
+			return nil
+		})
+	}
+}
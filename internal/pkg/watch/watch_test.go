@@ -0,0 +1,127 @@
+package watch_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"go.iscode.ca/mdg/internal/pkg/watch"
+)
+
+var backends = map[string][]watch.Option{
+	"notify": nil,
+	"poll":   {watch.WithPolling(true), watch.WithInterval(20 * time.Millisecond)},
+}
+
+// wait returns the files reported until the file is reported or a
+// timeout.
+func wait(w *watch.Watcher, file string) []string {
+	var files []string
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case batch := <-w.Events():
+			files = append(files, batch...)
+			if slices.Contains(batch, file) {
+				return files
+			}
+		case <-timeout:
+			return files
+		}
+	}
+}
+
+func write(t *testing.T, file, content string) bool {
+	t.Helper()
+
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+
+	return true
+}
+
+func TestWatchFile(t *testing.T) {
+	for name, opt := range backends {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "w.md")
+			other := filepath.Join(dir, "other.md")
+
+			if !write(t, file, "a") || !write(t, other, "a") {
+				return
+			}
+
+			w, err := watch.New([]string{file}, append(opt, watch.WithDelay(20*time.Millisecond))...)
+			if err != nil {
+				t.Errorf("%v", err)
+				return
+			}
+
+			defer w.Close()
+
+			// The poller scans the files when started.
+			time.Sleep(100 * time.Millisecond)
+
+			if !write(t, other, "bb") || !write(t, file, "bb") {
+				return
+			}
+
+			files := wait(w, file)
+
+			if !slices.Contains(files, file) {
+				t.Errorf("%s: expected modified file, got %v", file, files)
+			}
+
+			if slices.Contains(files, other) {
+				t.Errorf("%s: unexpected modified file", other)
+			}
+		})
+	}
+}
+
+func TestWatchDir(t *testing.T) {
+	for name, opt := range backends {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			w, err := watch.New([]string{dir}, append(opt, watch.WithDelay(20*time.Millisecond))...)
+			if err != nil {
+				t.Errorf("%v", err)
+				return
+			}
+
+			defer w.Close()
+
+			time.Sleep(100 * time.Millisecond)
+
+			sub := filepath.Join(dir, "sub")
+			if err := os.Mkdir(sub, 0o755); err != nil {
+				t.Errorf("%v", err)
+				return
+			}
+
+			file := filepath.Join(sub, "x.md")
+			hidden := filepath.Join(sub, ".x.md")
+
+			if !write(t, hidden, "a") || !write(t, file, "a") {
+				return
+			}
+
+			files := wait(w, file)
+
+			if !slices.Contains(files, file) {
+				t.Errorf("%s: expected new file, got %v", file, files)
+			}
+
+			if slices.Contains(files, hidden) {
+				t.Errorf("%s: unexpected hidden file", hidden)
+			}
+		})
+	}
+}
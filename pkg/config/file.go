@@ -0,0 +1,465 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of configuration files. Configuration files are
+// looked up in the directory of a document and its parents.
+const FileName = ".mdg.yaml"
+
+// EnvPrefix is the prefix of environment variables setting options:
+// MDG_NO_LINEWRAP=true sets the no-linewrap option.
+const EnvPrefix = "MDG_"
+
+// Value is the value of an option and its origin: the path of a
+// configuration file or the name of an environment variable.
+type Value struct {
+	Values []string
+	Source string
+
+	dir     string
+	command string
+}
+
+// file is a parsed configuration file. Options are mapped to command
+// line flags: top level options apply to all commands, options in a
+// section named after a command apply to the command.
+type file struct {
+	path     string
+	root     bool
+	options  map[string]Value
+	commands map[string]map[string]Value
+}
+
+// Config is the configuration of a directory.
+type Config struct {
+	// Files are the configuration files, from the farthest to the
+	// nearest.
+	Files []string
+
+	files []*file
+	env   map[string]Value
+}
+
+// Load reads the configuration files of the directory and its parents,
+// up to a configuration file containing "root: true", and the MDG_*
+// environment variables.
+func Load(dir string) (*Config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{
+		env: env(),
+	}
+
+	for {
+		f, err := readFile(filepath.Join(dir, FileName))
+		if err != nil {
+			return nil, err
+		}
+
+		if f != nil {
+			c.files = append([]*file{f}, c.files...)
+			c.Files = append([]string{f.path}, c.Files...)
+
+			if f.root {
+				break
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	return c, nil
+}
+
+// Options returns the options of the command. Options of nearer files
+// override options of farther files, command sections override top
+// level options of the same file and environment variables override
+// configuration files.
+func (c *Config) Options(command string) map[string]Value {
+	opt := make(map[string]Value)
+
+	for _, f := range c.files {
+		for k, v := range f.options {
+			opt[k] = v
+		}
+		for k, v := range f.commands[command] {
+			opt[k] = v
+		}
+	}
+
+	for k, v := range c.env {
+		opt[k] = v
+	}
+
+	return opt
+}
+
+// DirOptions are the options of the commands read from the configuration
+// of the directory of each document. The other options are read from the
+// configuration of the working directory.
+var DirOptions = map[string][]string{
+	"convert": {
+		"base-url", "css", "extend-css", "layouts", "naming", "no-attribute",
+		"no-definition-list", "no-footnote", "no-rewrite-links", "script",
+		"stylesheet", "template", "text-template", "typographer",
+	},
+	"fmt": {
+		"bullet", "code-fence", "emphasis", "front-matter-order", "gofmt",
+		"heading-style", "no-attribute", "no-definition-list", "no-footnote",
+		"no-linewrap", "no-verify", "ordered-list", "sentence-per-line",
+		"sort-front-matter", "strong", "style", "thematic-break", "width",
+	},
+}
+
+// Apply sets the flags of the command which are not set on the command
+// line to the configured options: flags must be parsed before Apply.
+// Relative paths of the path options are resolved from the directory of
+// the configuration file.
+func (c *Config) Apply(fs *flag.FlagSet, command string, paths ...string) error {
+	opt := c.Options(command)
+
+	names := make([]string, 0, len(opt))
+	for k := range opt {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for _, name := range names {
+		v := opt[name]
+
+		if fs.Lookup(name) == nil {
+			// Top level options may be options of other commands.
+			if v.command != "" {
+				return fmt.Errorf("%s: %s: unknown option: %s", v.Source, v.command, name)
+			}
+			continue
+		}
+
+		// The command line replaces the configured values.
+		if set[name] {
+			continue
+		}
+
+		isPath := false
+		for _, p := range paths {
+			isPath = isPath || p == name
+		}
+
+		for _, s := range v.Values {
+			if isPath && v.dir != "" && s != "" && !filepath.IsAbs(s) {
+				s = filepath.Join(v.dir, s)
+			}
+
+			if err := fs.Set(name, s); err != nil {
+				return fmt.Errorf("%s: %s: %w", v.Source, name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Nested removes the options read from the base configuration only from
+// the configuration files which are not files of the base configuration,
+// such as the configuration files of subdirectories of the working
+// directory: only the DirOptions of the command are kept. If fs is not
+// nil, the options which are not flags of fs are kept. If command is
+// empty, the command sections are checked with the DirOptions of each
+// command and the top level options which are DirOptions of a command
+// are kept. Nested returns a warning for each removed option.
+func (c *Config) Nested(base *Config, command string, fs *flag.FlagSet) []string {
+	var warnings []string
+
+	remove := func(f *file, options map[string]Value, names []string) {
+		for name, v := range options {
+			if slices.Contains(names, name) || fs != nil && fs.Lookup(name) == nil {
+				continue
+			}
+
+			option := name
+			if v.command != "" {
+				option = v.command + ": " + name
+			}
+
+			warnings = append(warnings, fmt.Sprintf("%s: %s: option read from the configuration of the working directory only: ignored", f.path, option))
+			delete(options, name)
+		}
+	}
+
+	var all []string
+	for _, names := range DirOptions {
+		all = append(all, names...)
+	}
+
+	for _, f := range c.files {
+		if slices.Contains(base.Files, f.path) {
+			continue
+		}
+
+		if command != "" {
+			remove(f, f.options, DirOptions[command])
+			remove(f, f.commands[command], DirOptions[command])
+			continue
+		}
+
+		remove(f, f.options, all)
+
+		for k, options := range f.commands {
+			remove(f, options, DirOptions[k])
+		}
+	}
+
+	sort.Strings(warnings)
+
+	return warnings
+}
+
+// Commands returns the names of the command sections.
+func (c *Config) Commands() []string {
+	var commands []string
+
+	for _, f := range c.files {
+		for k := range f.commands {
+			found := false
+			for _, v := range commands {
+				found = found || v == k
+			}
+			if !found {
+				commands = append(commands, k)
+			}
+		}
+	}
+
+	sort.Strings(commands)
+
+	return commands
+}
+
+// WriteTo writes the configuration as YAML. The source of each option
+// is written as a comment. Command sections contain the options which
+// differ from the top level options.
+func (c *Config) WriteTo(w io.Writer) (int64, error) {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+
+	for _, v := range c.Files {
+		doc.HeadComment += v + "\n"
+	}
+
+	global := c.Options("")
+	addOptions(doc, global)
+
+	for _, command := range c.Commands() {
+		opt := c.Options(command)
+
+		for k, v := range opt {
+			if g, ok := global[k]; ok && g.Source == v.Source && g.command == v.command {
+				delete(opt, k)
+			}
+		}
+
+		if len(opt) == 0 {
+			continue
+		}
+
+		section := &yaml.Node{Kind: yaml.MappingNode}
+		addOptions(section, opt)
+
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: command},
+			section,
+		)
+	}
+
+	if len(doc.Content) == 0 {
+		doc.Style = yaml.FlowStyle
+	}
+
+	var b strings.Builder
+
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(doc); err != nil {
+		return 0, err
+	}
+
+	n, err := io.WriteString(w, b.String())
+
+	return int64(n), err
+}
+
+func addOptions(node *yaml.Node, opt map[string]Value) {
+	names := make([]string, 0, len(opt))
+	for k := range opt {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	for _, k := range names {
+		v := opt[k]
+
+		value := &yaml.Node{Kind: yaml.ScalarNode}
+		if len(v.Values) == 1 {
+			value.Value = v.Values[0]
+		} else {
+			value.Kind = yaml.SequenceNode
+			value.Style = yaml.FlowStyle
+			for _, s := range v.Values {
+				value.Content = append(value.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: s})
+			}
+		}
+
+		value.LineComment = v.Source
+
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k}, value)
+	}
+}
+
+// env returns the options set by environment variables.
+func env() map[string]Value {
+	opt := make(map[string]Value)
+
+	for _, kv := range os.Environ() {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(k, EnvPrefix) || k == EnvPrefix {
+			continue
+		}
+
+		name := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(k, EnvPrefix), "_", "-"))
+
+		opt[name] = Value{Values: []string{v}, Source: k}
+	}
+
+	return opt
+}
+
+// readFile parses a configuration file. If the file does not exist,
+// readFile returns nil.
+func readFile(name string) (*file, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	f := &file{
+		path:     name,
+		options:  make(map[string]Value),
+		commands: make(map[string]map[string]Value),
+	}
+
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	if len(doc.Content) == 0 {
+		return f, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: expected a mapping of options", name)
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		k, v := root.Content[i].Value, root.Content[i+1]
+
+		if v.Kind == yaml.MappingNode {
+			opt, err := parseOptions(f, k, v)
+			if err != nil {
+				return nil, err
+			}
+			f.commands[k] = opt
+			continue
+		}
+
+		if k == "root" {
+			f.root = v.Value == "true"
+			continue
+		}
+
+		value, err := parseValue(f, "", v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", name, k, err)
+		}
+
+		f.options[k] = value
+	}
+
+	return f, nil
+}
+
+// parseOptions parses a command section.
+func parseOptions(f *file, command string, node *yaml.Node) (map[string]Value, error) {
+	opt := make(map[string]Value)
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k := node.Content[i].Value
+
+		value, err := parseValue(f, command, node.Content[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %s: %w", f.path, command, k, err)
+		}
+
+		opt[k] = value
+	}
+
+	return opt, nil
+}
+
+// parseValue parses a scalar or a list of scalars. Lists set repeatable
+// options.
+func parseValue(f *file, command string, node *yaml.Node) (Value, error) {
+	v := Value{
+		Source:  f.path,
+		dir:     filepath.Dir(f.path),
+		command: command,
+	}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		v.Values = []string{node.Value}
+	case yaml.SequenceNode:
+		for _, n := range node.Content {
+			if n.Kind != yaml.ScalarNode {
+				return v, errors.New("expected a list of values")
+			}
+			v.Values = append(v.Values, n.Value)
+		}
+	default:
+		return v, errors.New("expected a value or a list of values")
+	}
+
+	return v, nil
+}
@@ -0,0 +1,190 @@
+package config_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.iscode.ca/mdg/internal/pkg/list"
+	"go.iscode.ca/mdg/pkg/config"
+)
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "docs")
+
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	for name, content := range map[string]string{
+		filepath.Join(root, config.FileName): "root: true\ncss: site.css\nwidth: 40\nfmt:\n  bullet: \"-\"\n",
+		filepath.Join(dir, config.FileName):  "width: 20\nfmt:\n  emphasis: _\n",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+
+	t.Setenv("MDG_EMPHASIS", "*")
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	css := fs.String("css", "", "")
+	width := fs.Int("width", 0, "")
+	bullet := fs.String("bullet", "", "")
+	emphasis := fs.String("emphasis", "", "")
+
+	if err := fs.Parse([]string{"-bullet", "+"}); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := cfg.Apply(fs, "fmt", "css"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	for _, v := range []struct {
+		name     string
+		expected any
+		got      any
+	}{
+		{"css", filepath.Join(root, "site.css"), *css},
+		{"width", 20, *width},
+		{"bullet", "+", *bullet},
+		{"emphasis", "*", *emphasis},
+	} {
+		if v.expected != v.got {
+			t.Errorf("%s: expected %v, got %v", v.name, v.expected, v.got)
+		}
+	}
+
+	if err := cfg.Apply(flag.NewFlagSet("fmt", flag.ContinueOnError), "fmt"); err == nil {
+		t.Errorf("expected unknown option error")
+	}
+}
+
+func TestApplyRepeatable(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, config.FileName), []byte("root: true\nexclude: [a, b]\ninclude: c\n"), 0o644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+
+	var include, exclude list.Strings
+	fs.Var(&include, "include", "")
+	fs.Var(&exclude, "exclude", "")
+
+	if err := fs.Parse([]string{"-exclude", "d", "-exclude", "e"}); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := cfg.Apply(fs, "fmt"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if got := include.String(); got != "c" {
+		t.Errorf("include: expected c, got %s", got)
+	}
+
+	if got := exclude.String(); got != "d,e" {
+		t.Errorf("exclude: expected d,e, got %s", got)
+	}
+}
+
+func TestNested(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "docs")
+
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := os.WriteFile(filepath.Join(root, config.FileName), []byte("root: true\nj: 2\n"), 0o644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	base, err := config.Load(root)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	fs.Int("j", 1, "")
+	fs.String("naming", "", "")
+
+	for _, v := range []struct {
+		content string
+		command string
+		fs      *flag.FlagSet
+		name    string
+		ignored bool
+	}{
+		{content: "naming: index\n", command: "convert", fs: fs, name: "naming"},
+		{content: "convert:\n  naming: index\n", command: "convert", fs: fs, name: "naming"},
+		{content: "j: 4\n", command: "convert", fs: fs, name: "j", ignored: true},
+		{content: "convert:\n  j: 4\n", command: "convert", fs: fs, name: "j", ignored: true},
+		{content: "width: 4\n", command: "convert", fs: fs, name: "width"},
+		{content: "addr: x\n", command: "serve", name: "addr", ignored: true},
+		{content: "serve:\n  addr: x\n", command: "serve", name: "addr", ignored: true},
+		{content: "addr: x\n", name: "addr", ignored: true},
+		{content: "width: 4\n", name: "width"},
+		{content: "fmt:\n  j: 4\n", name: "j", ignored: true},
+	} {
+		if err := os.WriteFile(filepath.Join(dir, config.FileName), []byte(v.content), 0o644); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		cfg, err := config.Load(dir)
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		// The options of the base configuration are kept.
+		if warnings := base.Nested(base, v.command, v.fs); len(warnings) > 0 {
+			t.Errorf("%q: base: %v", v.content, warnings)
+		}
+
+		warnings := cfg.Nested(base, v.command, v.fs)
+
+		if ignored := len(warnings) > 0; ignored != v.ignored {
+			t.Errorf("%q: %s: expected ignored %v, got %v", v.content, v.command, v.ignored, warnings)
+		}
+
+		command := v.command
+		if command == "" {
+			command = "fmt"
+		}
+
+		opt := cfg.Options(command)
+
+		if got, ok := opt[v.name]; v.ignored && ok && got.Source != base.Files[0] {
+			t.Errorf("%q: %s: expected %s to be removed", v.content, v.command, v.name)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package format
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// The markdown renderer does not support extensions such as definition
+// lists and footnotes. The formatter preserves these blocks by
+// converting them to raw blocks containing the original source.
+
+var footnoteRegexp = regexp.MustCompile(`^\[\^[^\]\s]+\]:`)
+
+// footnoteBlockParser parses a footnote definition and any continuation
+// lines as a raw block.
+type footnoteBlockParser struct{}
+
+func (b *footnoteBlockParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (b *footnoteBlockParser) Open(_ ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+
+	pos := pc.BlockOffset()
+	if pos < 0 || !footnoteRegexp.Match(line[pos:]) {
+		return nil, parser.NoChildren
+	}
+
+	node := ast.NewHTMLBlock(ast.HTMLBlockType7)
+	node.Lines().Append(segment)
+	reader.AdvanceToEOL()
+
+	return node, parser.NoChildren
+}
+
+func (b *footnoteBlockParser) Continue(node ast.Node, reader text.Reader, _ parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	if util.IsBlank(line) || footnoteRegexp.Match(line) {
+		return parser.Close
+	}
+
+	node.Lines().Append(segment)
+	reader.AdvanceToEOL()
+
+	return parser.Continue | parser.NoChildren
+}
+
+func (b *footnoteBlockParser) Close(ast.Node, text.Reader, parser.Context) {}
+
+func (b *footnoteBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (b *footnoteBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// rawTransformer replaces definition lists with raw blocks.
+type rawTransformer struct{}
+
+func (t *rawTransformer) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
+	var nodes []ast.Node
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		if n.Kind() == extast.KindDefinitionList {
+			nodes = append(nodes, n)
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	for _, n := range nodes {
+		raw := ast.NewHTMLBlock(ast.HTMLBlockType7)
+
+		lines := sourceLines(reader.Source(), n)
+		for _, s := range lines {
+			raw.Lines().Append(s)
+		}
+
+		if len(lines) > 0 {
+			raw.SetBlankPreviousLines(blankBefore(reader.Source(), lines[0].Start))
+		}
+
+		n.Parent().ReplaceChild(n.Parent(), n, raw)
+	}
+}
+
+// sourceLines returns the lines of the source spanned by a block.
+// Indentation up to the column of the first line is removed from
+// continuation lines.
+func sourceLines(source []byte, n ast.Node) []text.Segment {
+	start, stop := -1, -1
+
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || c.Type() != ast.TypeBlock {
+			return ast.WalkContinue, nil
+		}
+
+		lines := c.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			s := lines.At(i)
+			if start < 0 || s.Start < start {
+				start = s.Start
+			}
+			if s.Stop > stop {
+				stop = s.Stop
+			}
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	if start < 0 {
+		return nil
+	}
+
+	bol := bytes.LastIndexByte(source[:start], '\n') + 1
+	col := start - bol
+
+	// include the remainder of the last line
+	if source[stop-1] != '\n' {
+		if i := bytes.IndexByte(source[stop:], '\n'); i >= 0 {
+			stop += i + 1
+		} else {
+			stop = len(source)
+		}
+	}
+
+	var segments []text.Segment
+
+	for pos := start; pos < stop; {
+		end := stop
+		if i := bytes.IndexByte(source[pos:stop], '\n'); i >= 0 {
+			end = pos + i + 1
+		}
+
+		indent := 0
+		for pos != start && indent < col && pos+indent < end &&
+			(source[pos+indent] == ' ' || source[pos+indent] == '\t') {
+			indent++
+		}
+
+		segments = append(segments, text.NewSegment(pos+indent, end))
+		pos = end
+	}
+
+	return segments
+}
+
+// blankBefore reports whether the line preceding the offset is blank.
+func blankBefore(source []byte, offset int) bool {
+	bol := bytes.LastIndexByte(source[:offset], '\n')
+	if bol < 0 {
+		return false
+	}
+
+	prev := bytes.LastIndexByte(source[:bol], '\n')
+
+	return util.IsBlank(source[prev+1 : bol])
+}
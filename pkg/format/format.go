@@ -3,16 +3,19 @@ package format
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"strings"
 
+	"go.iscode.ca/mdg/pkg/syntax"
+
 	"github.com/Kunde21/markdownfmt/v2/markdown"
 	"github.com/bwplotka/mdox/pkg/gitdiff"
 	"github.com/gohugoio/hugo/parser/pageparser"
 	"github.com/yuin/goldmark"
-	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/util"
 )
 
 type Markdown struct {
@@ -20,6 +23,7 @@ type Markdown struct {
 	Content     []byte
 	source      []byte
 	name        string
+	frontMatter *frontMatter
 }
 
 // Parse returns the frontmatter and markdown content.
@@ -49,18 +53,39 @@ func Parse(r io.Reader) (*Markdown, error) {
 	if len(fm.FrontMatter) > 0 {
 		md.Content = fm.Content
 		md.FrontMatter = fm.FrontMatter
+		md.frontMatter = parseFrontMatter(source, fm.Content)
 	}
 
 	return md, nil
 }
 
-// WriteFrontMatter converts the parsed metadata into YAML.
+// Name returns the file name of the document or an empty string if the
+// document was not read from a file.
+func (md *Markdown) Name() string {
+	return md.name
+}
+
+// WriteFrontMatter formats the front matter in the original format
+// (YAML, TOML or JSON), preserving the order of keys. Front matter not
+// parsed from a document is converted to YAML.
 func (md *Markdown) WriteFrontMatter(w io.Writer) error {
+	return md.writeFrontMatter(w, nil)
+}
+
+func (md *Markdown) writeFrontMatter(w io.Writer, order []string) error {
 	if len(md.FrontMatter) == 0 {
 		return nil
 	}
 
-	b, err := FormatFrontMatter(md.FrontMatter)
+	var b []byte
+	var err error
+
+	if md.frontMatter != nil {
+		b, err = md.frontMatter.format(order)
+	} else {
+		b, err = FormatFrontMatter(md.FrontMatter)
+	}
+
 	if err != nil {
 		return err
 	}
@@ -73,7 +98,13 @@ func (md *Markdown) WriteFrontMatter(w io.Writer) error {
 }
 
 type Formatter struct {
-	linewrap bool
+	linewrap  bool
+	width     int
+	sentences bool
+	profile   syntax.Profile
+	order     []string
+	style     Style
+	gofmt     bool
 }
 
 type Option func(*Formatter)
@@ -85,9 +116,71 @@ func WithLineWrap(t bool) Option {
 	}
 }
 
+// WithWidth reflows paragraphs, list items and blockquotes to the
+// column width. Inline code, links and URLs are not broken. A width of
+// 0 disables reflowing.
+func WithWidth(n int) Option {
+	return func(f *Formatter) {
+		f.width = max(n, 0)
+	}
+}
+
+// WithSentenceBreaks reflows paragraphs to place each sentence on a
+// separate line (semantic line breaks). If a width is set, long
+// sentences are also wrapped.
+func WithSentenceBreaks(t bool) Option {
+	return func(f *Formatter) {
+		f.sentences = t
+	}
+}
+
+// WithGoFormat enables or disables formatting the code blocks fenced
+// with the go language with gofmt. By default, code blocks are not
+// modified.
+func WithGoFormat(t bool) Option {
+	return func(f *Formatter) {
+		f.gofmt = t
+	}
+}
+
+// WithProfile sets the markdown syntax accepted by the formatter.
+func WithProfile(p syntax.Profile) Option {
+	return func(f *Formatter) {
+		f.profile = p
+	}
+}
+
+// WithDefinitionList enables or disables parsing definition lists. The
+// contents of definition lists are not formatted.
+func WithDefinitionList(t bool) Option {
+	return func(f *Formatter) {
+		f.profile.DefinitionList = t
+	}
+}
+
+// WithFootnote enables or disables parsing footnote definitions. The
+// contents of footnote definitions are not formatted.
+func WithFootnote(t bool) Option {
+	return func(f *Formatter) {
+		f.profile.Footnote = t
+	}
+}
+
+// WithFrontMatterOrder sorts front matter keys into a canonical order:
+// the listed keys are followed by the remaining keys in alphabetical
+// order. By default, the original order is preserved.
+func WithFrontMatterOrder(keys ...string) Option {
+	return func(f *Formatter) {
+		f.order = append([]string{}, keys...)
+	}
+}
+
 // New configures the formatter.
 func New(opt ...Option) *Formatter {
-	f := &Formatter{}
+	f := &Formatter{
+		profile: syntax.New(),
+		style:   DefaultStyle(),
+	}
 
 	for _, fn := range opt {
 		fn(f)
@@ -99,25 +192,82 @@ func New(opt ...Option) *Formatter {
 // Format formats and writes a parsed markdown document to the provided
 // writer.
 func (f *Formatter) Format(w io.Writer, md *Markdown) error {
-	if err := md.WriteFrontMatter(w); err != nil {
+	if err := md.writeFrontMatter(w, f.order); err != nil {
 		return err
 	}
 
+	reflow := f.width > 0 || f.sentences
+
+	transformers := []util.PrioritizedValue{
+		util.Prioritized(&styleTransformer{style: f.style, gofmt: f.gofmt}, 100),
+	}
+
+	// Paragraphs are rendered on a single line and reflowed.
+	if reflow {
+		transformers = append(transformers, util.Prioritized(&hardBreakTransformer{}, 100))
+	}
+
+	var b bytes.Buffer
+
+	if err := f.goldmark(f.linewrap && !reflow, transformers...).Convert(md.Content, &b); err != nil {
+		return err
+	}
+
+	out := b.Bytes()
+
+	if reflow {
+		out = f.wrap(out)
+	}
+
+	_, err := w.Write(out)
+
+	return err
+}
+
+// goldmark returns the markdown formatter for the syntax profile.
+func (f *Formatter) goldmark(softwrap bool, transformers ...util.PrioritizedValue) goldmark.Markdown {
 	renderer := markdown.NewRenderer()
-	if f.linewrap {
+	if softwrap {
 		renderer.AddMarkdownOptions(markdown.WithSoftWraps())
 	}
 
+	if f.style.Heading == HeadingSetext {
+		renderer.AddMarkdownOptions(markdown.WithUnderlineHeadings())
+	}
+
 	return goldmark.New(
-		goldmark.WithExtensions(
-			extension.GFM,
-		),
-		goldmark.WithParserOptions(
-			parser.WithAttribute(), /* Enable # headers {#custom-ids} */
-			parser.WithHeadingAttribute(),
-		),
+		goldmark.WithParser(f.parser(transformers...)),
 		goldmark.WithRenderer(renderer),
-	).Convert(md.Content, w)
+	)
+}
+
+// parser returns the markdown parser for the syntax profile.
+func (f *Formatter) parser(transformers ...util.PrioritizedValue) parser.Parser {
+	// Footnote definitions are parsed as raw blocks: the footnote
+	// extension moves definitions to the end of the document.
+	p := f.profile
+	p.Footnote = false
+
+	parserOpts := p.ParserOptions()
+
+	if f.profile.DefinitionList {
+		transformers = append(transformers, util.Prioritized(&rawTransformer{}, 100))
+	}
+
+	if len(transformers) > 0 {
+		parserOpts = append(parserOpts, parser.WithASTTransformers(transformers...))
+	}
+
+	if f.profile.Footnote {
+		parserOpts = append(parserOpts, parser.WithBlockParsers(
+			util.Prioritized(&footnoteBlockParser{}, 100),
+		))
+	}
+
+	return goldmark.New(
+		goldmark.WithExtensions(p.Extensions()...),
+		goldmark.WithParserOptions(parserOpts...),
+	).Parser()
 }
 
 // Diff verifies if the document has been formatted. If the document
@@ -142,6 +292,38 @@ func (f *Formatter) Diff(md *Markdown) (string, error) {
 	return string(d.ToCombinedFormat()), nil
 }
 
+// Result is the formatting status of a markdown document.
+type Result struct {
+	// Name is the file name of the document or an empty string if the
+	// document was not read from a file.
+	Name string
+	// Formatted is true if the document does not require formatting.
+	Formatted bool
+	// Diff contains the formatting changes for an unformatted document.
+	Diff string
+}
+
+// Check reports the formatting status of each document. The documents
+// are not modified.
+func (f *Formatter) Check(mds ...*Markdown) ([]Result, error) {
+	results := make([]Result, 0, len(mds))
+
+	for _, md := range mds {
+		diff, err := f.Diff(md)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", md.name, err)
+		}
+
+		results = append(results, Result{
+			Name:      md.name,
+			Formatted: diff == "",
+			Diff:      diff,
+		})
+	}
+
+	return results, nil
+}
+
 func String(key string, fm map[string]any) string {
 	val, ok := fm[key]
 	if !ok {
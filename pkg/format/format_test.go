@@ -2,6 +2,10 @@ package format_test
 
 import (
 	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"go.iscode.ca/mdg/pkg/format"
@@ -57,11 +61,11 @@ option
 `
 
 	mdFrontMatterFormatted = `---
-version: 1.0.0
+author: Firstname Lastname
 title: The Title Goes Here
-status: proposal
 date: "2022-10-27"
-author: Firstname Lastname
+version: 1.0.0
+status: proposal
 ---
 
 # Test
@@ -162,3 +166,587 @@ func TestNoDiffMarkdown(t *testing.T) {
 		return
 	}
 }
+
+func TestCheckMarkdown(t *testing.T) {
+	unformatted, err := format.Parse(bytes.NewBufferString(mdFrontMatterUnformatted))
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	formatted, err := format.Parse(bytes.NewBufferString(mdFrontMatterFormatted))
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	f := format.New(format.WithLineWrap(true))
+
+	results, err := f.Check(unformatted, formatted)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if len(results) != 2 {
+		t.Errorf("check: expected 2 results: %d", len(results))
+		return
+	}
+
+	if results[0].Formatted || results[0].Diff == "" {
+		t.Errorf("check: unformatted markdown reported as formatted")
+		return
+	}
+
+	if !results[1].Formatted || results[1].Diff != "" {
+		t.Errorf("check: formatted markdown reported as unformatted: %s", results[1].Diff)
+		return
+	}
+}
+
+const mdExtensions = `# Extensions
+
+Text with a footnote[^1] and "quotes".
+
+[^1]: The footnote
+    continues here.
+
+term *emphasis*
+: definition   with  spaces
+  continued
+
+* list
+
+  nested term
+  : nested definition
+
+[^note]: Footnote after content.
+`
+
+func TestFormatExtensions(t *testing.T) {
+	for _, linewrap := range []bool{true, false} {
+		md, err := format.Parse(bytes.NewBufferString(mdExtensions))
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		f := format.New(format.WithLineWrap(linewrap))
+
+		diff, err := f.Diff(md)
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		if diff != "" {
+			t.Errorf("markdown: differences found: %s", diff)
+			return
+		}
+	}
+}
+
+func TestFormatFrontMatter(t *testing.T) {
+	for _, v := range []struct {
+		name      string
+		opt       []format.Option
+		md        string
+		formatted string
+	}{
+		{
+			"yaml",
+			nil,
+			"---\n# comment\ntitle: Title   # line comment\ntags:\n    - a\n    - b\nauthor: Name\n---\n\n# Test\n",
+			"---\n# comment\ntitle: Title # line comment\ntags:\n  - a\n  - b\nauthor: Name\n---\n\n# Test\n",
+		},
+		{
+			"yaml order",
+			[]format.Option{format.WithFrontMatterOrder("title", "date")},
+			"---\nstatus: draft\ndate: 2022-10-27\nauthor: Name\ntitle: Title\n---\n\n# Test\n",
+			"---\ntitle: Title\ndate: 2022-10-27\nauthor: Name\nstatus: draft\n---\n\n# Test\n",
+		},
+		{
+			"toml",
+			nil,
+			"+++\n# comment\ntitle = 'Title'\ndate = 2022-10-27\n\n[params]\nauthor = 'Name'\n+++\n\n# Test\n",
+			"+++\n# comment\ntitle = 'Title'\ndate = 2022-10-27\n\n[params]\nauthor = 'Name'\n+++\n\n# Test\n",
+		},
+		{
+			"toml order",
+			[]format.Option{format.WithFrontMatterOrder("title")},
+			"+++\ndate = 2022-10-27\ntitle = 'Title'\n[params]\nauthor = 'Name'\n\n[[menu]]\nx = 1\n+++\n\n# Test\n",
+			"+++\ntitle = 'Title'\ndate = 2022-10-27\n\n[[menu]]\nx = 1\n\n[params]\nauthor = 'Name'\n+++\n\n# Test\n",
+		},
+		{
+			"json",
+			nil,
+			"{\"title\": \"Title\", \"date\": \"2022-10-27\"}\n\n# Test\n",
+			"{\n  \"title\": \"Title\",\n  \"date\": \"2022-10-27\"\n}\n\n# Test\n",
+		},
+		{
+			"json order",
+			[]format.Option{format.WithFrontMatterOrder("date")},
+			"{\"title\": \"Title\", \"author\": \"Name\", \"date\": \"2022-10-27\"}\n\n# Test\n",
+			"{\n  \"date\": \"2022-10-27\",\n  \"author\": \"Name\",\n  \"title\": \"Title\"\n}\n\n# Test\n",
+		},
+	} {
+		md, err := format.Parse(bytes.NewBufferString(v.md))
+		if err != nil {
+			t.Errorf("%s: %v", v.name, err)
+			continue
+		}
+
+		b := &bytes.Buffer{}
+
+		if err := format.New(v.opt...).Format(b, md); err != nil {
+			t.Errorf("%s: %v", v.name, err)
+			continue
+		}
+
+		if b.String() != v.formatted {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", v.name, v.formatted, b.String())
+		}
+	}
+}
+
+const (
+	mdWrapUnformatted = `A paragraph with ` + "`inline code with spaces`" + ` and a [link with text](https://example.com/path) to wrap.
+Joined line ending with a hard break\
+after the break - 1. no list.
+
+* A list item which is long enough to be wrapped.
+
+> A blockquote which is long enough to be wrapped.
+
+日本語の文章はとても長いです 日本語の文章はとても長いです
+`
+
+	mdWrapFormatted = `A paragraph with
+` + "`inline code with spaces`" + ` and a
+[link with text](https://example.com/path)
+to wrap. Joined line ending with a hard
+break\
+after the break - 1. no list.
+
+* A list item which is long enough to be
+  wrapped.
+
+> A blockquote which is long enough to
+> be wrapped.
+
+日本語の文章はとても長いです
+日本語の文章はとても長いです
+`
+
+	mdSentenceUnformatted = `First sentence. Second sentence, e.g. with
+an abbreviation! Is this the third? Yes.
+`
+
+	mdSentenceFormatted = `First sentence.
+Second sentence, e.g. with an abbreviation!
+Is this the third?
+Yes.
+`
+)
+
+func TestFormatWrap(t *testing.T) {
+	for _, v := range []struct {
+		name        string
+		opt         []format.Option
+		unformatted string
+		formatted   string
+	}{
+		{"width", []format.Option{format.WithWidth(40)}, mdWrapUnformatted, mdWrapFormatted},
+		{"sentences", []format.Option{format.WithSentenceBreaks(true)}, mdSentenceUnformatted, mdSentenceFormatted},
+	} {
+		for _, in := range []string{v.unformatted, v.formatted} {
+			md, err := format.Parse(bytes.NewBufferString(in))
+			if err != nil {
+				t.Errorf("%s: %v", v.name, err)
+				return
+			}
+
+			b := &bytes.Buffer{}
+
+			if err := format.New(v.opt...).Format(b, md); err != nil {
+				t.Errorf("%s: %v", v.name, err)
+				return
+			}
+
+			if b.String() != v.formatted {
+				t.Errorf("%s: expected:\n%s\ngot:\n%s", v.name, v.formatted, b.String())
+			}
+		}
+	}
+}
+
+const (
+	mdStyleUnformatted = `# Title
+
+Some *em*, **strong** and snake*case*word.
+
+* a
+* b
+
+- c
+
+1. one
+2. two
+3. three
+
+***
+
+` + "```go" + `
+package main
+func main(){}
+` + "```" + `
+`
+
+	mdStyleFormatted = `Title
+=====
+
+Some _em_, __strong__ and snake*case*word.
+
+- a
+- b
+
+* c
+
+1. one
+1. two
+1. three
+
+___
+
+~~~go
+package main
+func main(){}
+~~~
+`
+)
+
+func TestFormatStyle(t *testing.T) {
+	style := format.Style{
+		Heading:       format.HeadingSetext,
+		Bullet:        "-",
+		Emphasis:      "_",
+		Strong:        "__",
+		CodeFence:     "~~~",
+		OrderedList:   format.NumberingOne,
+		ThematicBreak: "___",
+	}
+
+	if err := style.Validate(); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	for _, in := range []string{mdStyleUnformatted, mdStyleFormatted} {
+		md, err := format.Parse(bytes.NewBufferString(in))
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		b := &bytes.Buffer{}
+
+		if err := format.New(format.WithStyle(style)).Format(b, md); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		if b.String() != mdStyleFormatted {
+			t.Errorf("expected:\n%s\ngot:\n%s", mdStyleFormatted, b.String())
+		}
+	}
+}
+
+func TestFormatOrderedList(t *testing.T) {
+	style := format.DefaultStyle()
+	style.OrderedList = format.NumberingOne
+
+	// Nested lists start on the line of their parent item and the
+	// numbers in code blocks are not list items.
+	in := `1. 1. a
+   2. b
+
+      ` + "```" + `
+      2. code
+      ` + "```" + `
+2. c
+   1. d
+   2. e
+3. f
+
+   5. x
+   6. y
+
+7) g
+8) h
+`
+
+	expected := `1. 1. a
+   1. b
+
+      ` + "```" + `
+      2. code
+      ` + "```" + `
+1. c
+   1. d
+   1. e
+1. f
+
+   5. x
+   5. y
+
+7) g
+7) h
+`
+
+	for _, v := range []string{in, expected} {
+		b, err := formatBytes(format.New(format.WithStyle(style)), []byte(v))
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		if string(b) != expected {
+			t.Errorf("expected:\n%s\ngot:\n%s", expected, b)
+		}
+	}
+}
+
+func TestFormatGoCode(t *testing.T) {
+	in := "* item\n\n  ```go\n  func f(){}\n  ```\n\n```go\nfunc f(){}\n```\n"
+
+	for _, v := range []struct {
+		name     string
+		opt      []format.Option
+		expected string
+	}{
+		{"default", nil, "* item\n\n  ```go\n  func f(){}\n  ```\n\n```go\nfunc f(){}\n```\n"},
+		{"gofmt", []format.Option{format.WithGoFormat(true)}, "* item\n\n  ```go\n  func f() {}\n  ```\n\n```go\nfunc f() {}\n```\n"},
+		{"gofmt ~~~", []format.Option{format.WithGoFormat(true), format.WithStyle(format.Style{
+			Heading:       format.HeadingATX,
+			Emphasis:      "*",
+			Strong:        "**",
+			CodeFence:     "~~~",
+			OrderedList:   format.NumberingSequential,
+			ThematicBreak: "---",
+		})}, "* item\n\n  ~~~go\n  func f() {}\n  ~~~\n\n~~~go\nfunc f() {}\n~~~\n"},
+	} {
+		b, err := formatBytes(format.New(v.opt...), []byte(in))
+		if err != nil {
+			t.Errorf("%s: %v", v.name, err)
+			continue
+		}
+
+		if string(b) != v.expected {
+			t.Errorf("%s: expected %q, got %q", v.name, v.expected, b)
+		}
+	}
+}
+
+func TestLoadStyle(t *testing.T) {
+	style, err := format.LoadStyle(bytes.NewBufferString("bullet: \"*\"\nordered_list: one\n"))
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	expected := format.DefaultStyle()
+	expected.Bullet = "*"
+	expected.OrderedList = format.NumberingOne
+
+	if style != expected {
+		t.Errorf("expected %+v, got %+v", expected, style)
+	}
+
+	if _, err := format.LoadStyle(bytes.NewBufferString("emphasis: +\n")); err == nil {
+		t.Errorf("expected invalid emphasis error")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	f := format.New(format.WithWidth(20))
+
+	md, err := format.Parse(bytes.NewBufferString(mdStyleUnformatted))
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	b := &bytes.Buffer{}
+
+	if err := f.Format(b, md); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := f.Verify(md, b.Bytes()); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// The lists are merged.
+	changed := strings.Replace(b.String(), "- c", "* c", 1)
+
+	var verr *format.VerifyError
+
+	if err := f.Verify(md, []byte(changed)); !errors.As(err, &verr) {
+		t.Errorf("expected verify error, got %v", err)
+	}
+}
+
+func TestVerifyCode(t *testing.T) {
+	f := format.New()
+
+	const python = "```python\nif x:\n    y()\n```\n"
+
+	md, err := format.Parse(bytes.NewBufferString(python))
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	for _, v := range []struct {
+		name      string
+		formatted string
+		err       bool
+	}{
+		{name: "reindented", formatted: "```python\nif x:\n  y()\n```\n", err: true},
+		{name: "joined", formatted: "```python\nif x: y()\n```\n", err: true},
+		{name: "fence", formatted: "~~~python\nif x:\n    y()\n~~~\n"},
+	} {
+		var verr *format.VerifyError
+
+		if err := f.Verify(md, []byte(v.formatted)); errors.As(err, &verr) != v.err {
+			t.Errorf("%s: expected verify error %v, got %v", v.name, v.err, err)
+		}
+	}
+
+	// Go code is compared once formatted with gofmt.
+	md, err = format.Parse(bytes.NewBufferString("```go\nfunc f() {\n  g()\n}\n```\n"))
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := f.Verify(md, []byte("```go\nfunc f() {\n\tg()\n}\n```\n")); err != nil {
+		t.Errorf("go: %v", err)
+	}
+}
+
+func TestFormatIdempotent(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.md")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if len(files) == 0 {
+		t.Errorf("no test documents")
+		return
+	}
+
+	for _, v := range []struct {
+		name string
+		opt  []format.Option
+	}{
+		{"default", nil},
+		{"no linewrap", []format.Option{format.WithLineWrap(false)}},
+		{"width", []format.Option{format.WithWidth(40)}},
+		{"sentences", []format.Option{format.WithSentenceBreaks(true)}},
+		{"front matter order", []format.Option{format.WithFrontMatterOrder("title")}},
+		{"style", []format.Option{format.WithStyle(format.Style{
+			Heading:       format.HeadingSetext,
+			Bullet:        "-",
+			Emphasis:      "_",
+			Strong:        "__",
+			CodeFence:     "~~~",
+			OrderedList:   format.NumberingOne,
+			ThematicBreak: "___",
+		})}},
+	} {
+		f := format.New(append([]format.Option{format.WithLineWrap(true)}, v.opt...)...)
+
+		for _, file := range files {
+			source, err := os.ReadFile(file)
+			if err != nil {
+				t.Errorf("%v", err)
+				return
+			}
+
+			first, err := formatBytes(f, source)
+			if err != nil {
+				t.Errorf("%s: %s: %v", v.name, file, err)
+				continue
+			}
+
+			second, err := formatBytes(f, first)
+			if err != nil {
+				t.Errorf("%s: %s: %v", v.name, file, err)
+				continue
+			}
+
+			if !bytes.Equal(first, second) {
+				t.Errorf("%s: %s: second pass differs:\nfirst:\n%s\nsecond:\n%s", v.name, file, first, second)
+			}
+
+			md, err := format.Parse(bytes.NewReader(source))
+			if err != nil {
+				t.Errorf("%s: %s: %v", v.name, file, err)
+				continue
+			}
+
+			if err := f.Verify(md, first); err != nil {
+				t.Errorf("%s: %s: %v", v.name, file, err)
+			}
+		}
+	}
+}
+
+func formatBytes(f *format.Formatter, source []byte) ([]byte, error) {
+	md, err := format.Parse(bytes.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+
+	b := &bytes.Buffer{}
+
+	if err := f.Format(b, md); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func TestFormatFrontMatterIdempotent(t *testing.T) {
+	md := &format.Markdown{
+		FrontMatter: map[string]any{
+			"title":  "Title",
+			"tags":   []any{"a", "b"},
+			"params": map[string]any{"author": "Name", "list": []any{"x"}},
+		},
+		Content: []byte("# Test\n"),
+	}
+
+	f := format.New()
+
+	b := &bytes.Buffer{}
+
+	if err := f.Format(b, md); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	second, err := formatBytes(f, b.Bytes())
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if !bytes.Equal(b.Bytes(), second) {
+		t.Errorf("second pass differs:\nfirst:\n%s\nsecond:\n%s", b.Bytes(), second)
+	}
+}
@@ -0,0 +1,276 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/gohugoio/hugo/parser/metadecoders"
+	"github.com/gohugoio/hugo/parser/pageparser"
+	toml "github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatter is the front matter as written in the document.
+type frontMatter struct {
+	kind metadecoders.Format
+	// source is the front matter without delimiters.
+	source []byte
+	// raw is the front matter including delimiters.
+	raw []byte
+}
+
+// parseFrontMatter returns the front matter source of a document.
+func parseFrontMatter(source []byte, content []byte) *frontMatter {
+	items, err := pageparser.ParseBytes(source, pageparser.Config{})
+	if err != nil {
+		return nil
+	}
+
+	fm := &frontMatter{
+		raw: source[:len(source)-len(content)],
+	}
+
+	pageparser.NewIterator(items).PeekWalk(func(item pageparser.Item) bool {
+		if !item.IsFrontMatter() {
+			return true
+		}
+
+		fm.kind = pageparser.FormatFromFrontMatterType(item.Type)
+		fm.source = item.Val(source)
+
+		return false
+	})
+
+	return fm
+}
+
+// format formats the front matter in the original format. If order is
+// not nil, keys are sorted into canonical order.
+func (fm *frontMatter) format(order []string) ([]byte, error) {
+	switch fm.kind {
+	case metadecoders.YAML:
+		return formatYAML(fm.source, order)
+	case metadecoders.TOML:
+		return formatTOML(fm.source, order)
+	case metadecoders.JSON:
+		return formatJSON(fm.source, order)
+	}
+
+	return fm.raw, nil
+}
+
+// canonicalOrder returns the keys listed in order followed by the
+// remaining keys in alphabetical order.
+func canonicalOrder(keys []string, order []string) []string {
+	sorted := make([]string, 0, len(keys))
+
+	for _, k := range order {
+		if slices.Contains(keys, k) && !slices.Contains(sorted, k) {
+			sorted = append(sorted, k)
+		}
+	}
+
+	var rest []string
+	for _, k := range keys {
+		if !slices.Contains(sorted, k) {
+			rest = append(rest, k)
+		}
+	}
+
+	slices.Sort(rest)
+
+	return append(sorted, rest...)
+}
+
+// formatYAML preserves key order and comments.
+func formatYAML(source []byte, order []string) ([]byte, error) {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(source, &doc); err != nil {
+		return nil, fmt.Errorf("unmarshal front matter: %w", err)
+	}
+
+	if order != nil && len(doc.Content) == 1 && doc.Content[0].Kind == yaml.MappingNode {
+		m := doc.Content[0]
+
+		keys := make([]string, 0, len(m.Content)/2)
+		pairs := make(map[string][]*yaml.Node)
+
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			k := m.Content[i].Value
+			keys = append(keys, k)
+			pairs[k] = m.Content[i : i+2]
+		}
+
+		content := make([]*yaml.Node, 0, len(m.Content))
+		for _, k := range canonicalOrder(keys, order) {
+			content = append(content, pairs[k]...)
+		}
+
+		m.Content = content
+	}
+
+	b := bytes.NewBufferString("---\n")
+
+	enc := yaml.NewEncoder(b)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("marshal front matter: %w", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("marshal front matter: %w", err)
+	}
+
+	_, _ = b.WriteString("---\n\n")
+
+	return b.Bytes(), nil
+}
+
+// formatTOML returns the TOML source unchanged except for surrounding
+// blank lines. Comments are discarded when sorting keys.
+func formatTOML(source []byte, order []string) ([]byte, error) {
+	b := bytes.NewBufferString("+++\n")
+
+	if order == nil {
+		_, _ = b.Write(bytes.TrimRight(bytes.TrimLeft(source, "\r\n"), " \t\r\n"))
+		_, _ = b.WriteString("\n+++\n\n")
+		return b.Bytes(), nil
+	}
+
+	var m map[string]any
+
+	if err := toml.Unmarshal(source, &m); err != nil {
+		return nil, fmt.Errorf("unmarshal front matter: %w", err)
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	keys = canonicalOrder(keys, order)
+
+	// Keys must precede tables.
+	var tables []string
+
+	for _, k := range keys {
+		if isTable(m[k]) {
+			tables = append(tables, k)
+			continue
+		}
+
+		if err := encodeTOML(b, k, m[k]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, k := range tables {
+		_ = b.WriteByte('\n')
+
+		if err := encodeTOML(b, k, m[k]); err != nil {
+			return nil, err
+		}
+	}
+
+	_, _ = b.WriteString("+++\n\n")
+
+	return b.Bytes(), nil
+}
+
+// isTable reports whether the value is encoded as a table or an array
+// of tables.
+func isTable(v any) bool {
+	switch t := v.(type) {
+	case map[string]any:
+		return true
+	case []any:
+		for _, e := range t {
+			if _, ok := e.(map[string]any); !ok {
+				return false
+			}
+		}
+		return len(t) > 0
+	}
+
+	return false
+}
+
+func encodeTOML(b *bytes.Buffer, k string, v any) error {
+	o, err := toml.Marshal(map[string]any{k: v})
+	if err != nil {
+		return fmt.Errorf("marshal front matter: %w", err)
+	}
+
+	_, _ = b.Write(o)
+
+	return nil
+}
+
+// formatJSON indents the JSON source preserving key order.
+func formatJSON(source []byte, order []string) ([]byte, error) {
+	if order != nil {
+		dec := json.NewDecoder(bytes.NewReader(source))
+
+		if _, err := dec.Token(); err != nil {
+			return nil, fmt.Errorf("unmarshal front matter: %w", err)
+		}
+
+		var keys []string
+		values := make(map[string]json.RawMessage)
+
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return nil, fmt.Errorf("unmarshal front matter: %w", err)
+			}
+
+			k, ok := t.(string)
+			if !ok {
+				return nil, fmt.Errorf("unmarshal front matter: invalid key: %v", t)
+			}
+
+			var v json.RawMessage
+			if err := dec.Decode(&v); err != nil {
+				return nil, fmt.Errorf("unmarshal front matter: %w", err)
+			}
+
+			keys = append(keys, k)
+			values[k] = v
+		}
+
+		var obj bytes.Buffer
+
+		_ = obj.WriteByte('{')
+		for i, k := range canonicalOrder(keys, order) {
+			if i > 0 {
+				_ = obj.WriteByte(',')
+			}
+
+			key, err := json.Marshal(k)
+			if err != nil {
+				return nil, fmt.Errorf("marshal front matter: %w", err)
+			}
+
+			_, _ = obj.Write(key)
+			_ = obj.WriteByte(':')
+			_, _ = obj.Write(values[k])
+		}
+		_ = obj.WriteByte('}')
+
+		source = obj.Bytes()
+	}
+
+	var b bytes.Buffer
+
+	if err := json.Indent(&b, bytes.TrimSpace(source), "", "  "); err != nil {
+		return nil, fmt.Errorf("marshal front matter: %w", err)
+	}
+
+	_, _ = b.WriteString("\n\n")
+
+	return b.Bytes(), nil
+}
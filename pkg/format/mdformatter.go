@@ -26,12 +26,17 @@ func FormatFrontMatter(m map[string]interface{}) ([]byte, error) {
 		keys: keys,
 	}
 
+	// The indentation of parsed front matter is used so formatting the
+	// document again does not change the front matter.
 	b := bytes.NewBuffer([]byte("---\n"))
-	o, err := yaml.Marshal(f)
-	if err != nil {
+	enc := yaml.NewEncoder(b)
+	enc.SetIndent(2)
+	if err := enc.Encode(f); err != nil {
+		return nil, fmt.Errorf("marshall front matter: %w", err)
+	}
+	if err := enc.Close(); err != nil {
 		return nil, fmt.Errorf("marshall front matter: %w", err)
 	}
-	_, _ = b.Write(o)
 	_, _ = b.Write([]byte("---\n\n"))
 	return b.Bytes(), nil
 }
@@ -64,7 +69,6 @@ func (f sortedFrontMatter) MarshalYAML() (interface{}, error) {
 		if len(v.Content) != 1 {
 			return nil, fmt.Errorf("unexpected node after unmarshalling interface: %#v", v)
 		}
-		// TODO(bwplotka): This creates weird indentation, fix it.
 		n.Content = append(n.Content, v.Content[0])
 	}
 	return n, nil
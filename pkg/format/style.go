@@ -0,0 +1,300 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	goformat "go/format"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"gopkg.in/yaml.v3"
+)
+
+// Heading styles.
+const (
+	HeadingATX    = "atx"
+	HeadingSetext = "setext"
+)
+
+// Ordered list numbering styles.
+const (
+	NumberingSequential = "sequential"
+	NumberingOne        = "one"
+)
+
+// Style is the markdown syntax written by the formatter when a
+// construct has several equivalent forms.
+type Style struct {
+	// Heading is atx (# Heading) or setext (underlined level 1 and 2
+	// headings).
+	Heading string `yaml:"heading"`
+	// Bullet is the unordered list marker: -, * or +. If empty, the
+	// original marker is kept.
+	Bullet string `yaml:"bullet"`
+	// Emphasis is * or _.
+	Emphasis string `yaml:"emphasis"`
+	// Strong is ** or __.
+	Strong string `yaml:"strong"`
+	// CodeFence is ``` or ~~~.
+	CodeFence string `yaml:"code_fence"`
+	// OrderedList is sequential (1. 2. 3.) or one (1. 1. 1.).
+	OrderedList string `yaml:"ordered_list"`
+	// ThematicBreak is ---, *** or ___.
+	ThematicBreak string `yaml:"thematic_break"`
+}
+
+// DefaultStyle returns the style of the markdown renderer.
+func DefaultStyle() Style {
+	return Style{
+		Heading:       HeadingATX,
+		Emphasis:      "*",
+		Strong:        "**",
+		CodeFence:     "```",
+		OrderedList:   NumberingSequential,
+		ThematicBreak: "---",
+	}
+}
+
+// LoadStyle reads a YAML style file. Settings not present in the file
+// are set to the default style.
+func LoadStyle(r io.Reader) (Style, error) {
+	s := DefaultStyle()
+
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+
+	if err := dec.Decode(&s); err != nil && !errors.Is(err, io.EOF) {
+		return s, err
+	}
+
+	return s, s.Validate()
+}
+
+// Validate returns an error if a setting is not supported.
+func (s Style) Validate() error {
+	check := func(name, v string, valid ...string) error {
+		for _, x := range valid {
+			if v == x {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid %s style: %q (expected %s)", name, v, strings.Join(valid, ", "))
+	}
+
+	return errors.Join(
+		check("heading", s.Heading, HeadingATX, HeadingSetext),
+		check("bullet", s.Bullet, "", "-", "*", "+"),
+		check("emphasis", s.Emphasis, "*", "_"),
+		check("strong", s.Strong, "**", "__"),
+		check("code fence", s.CodeFence, "```", "~~~"),
+		check("ordered list", s.OrderedList, NumberingSequential, NumberingOne),
+		check("thematic break", s.ThematicBreak, "---", "***", "___"),
+	)
+}
+
+// WithStyle sets the markdown style. By default, the style of the
+// markdown renderer is used.
+func WithStyle(s Style) Option {
+	return func(f *Formatter) {
+		f.style = s
+	}
+}
+
+// styleTransformer rewrites the nodes the markdown renderer writes in a
+// fixed style. Nodes are replaced by strings written verbatim.
+type styleTransformer struct {
+	style Style
+	gofmt bool
+}
+
+func (t *styleTransformer) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
+	source := reader.Source()
+
+	var nodes []ast.Node
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			nodes = append(nodes, n)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *ast.List:
+			switch {
+			case n.IsOrdered() && t.style.OrderedList == NumberingOne:
+				split(n)
+			case !n.IsOrdered() && t.style.Bullet != "":
+				n.Marker = t.bullet(n)
+			}
+		case *ast.Emphasis:
+			marker := t.style.Emphasis
+			if n.Level == 2 {
+				marker = t.style.Strong
+			}
+			if marker[0] != '*' && !intraword(n, source) {
+				unwrap(n, marker)
+			}
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			// The markdown renderer formats Go code.
+			if t.style.CodeFence != "```" || !t.gofmt && golang(n, source) {
+				n.Parent().ReplaceChild(n.Parent(), n, verbatim(fence(n, source, t.style.CodeFence[0], t.gofmt)))
+			}
+		case *ast.ThematicBreak:
+			if t.style.ThematicBreak != "---" {
+				n.Parent().ReplaceChild(n.Parent(), n, verbatim(t.style.ThematicBreak))
+			}
+		}
+	}
+}
+
+// bullet returns the list marker. Adjacent lists must use different
+// markers or they are merged.
+func (t *styleTransformer) bullet(list *ast.List) byte {
+	prev, ok := list.PreviousSibling().(*ast.List)
+	if !ok || prev.IsOrdered() {
+		return t.style.Bullet[0]
+	}
+
+	for _, c := range []byte(t.style.Bullet + "-*+") {
+		if c != prev.Marker {
+			return c
+		}
+	}
+
+	return list.Marker
+}
+
+// split moves each item but the first of an ordered list to a list of
+// its own. The markdown renderer numbers the items from the start number
+// of their list, so all the items are numbered with the start number.
+func split(list *ast.List) {
+	parent := list.Parent()
+
+	for item := list.LastChild(); item != list.FirstChild(); item = list.LastChild() {
+		l := ast.NewList(list.Marker)
+		l.IsTight = list.IsTight
+		l.Start = list.Start
+		l.SetBlankPreviousLines(item.HasBlankPreviousLines())
+
+		list.RemoveChild(list, item)
+		l.AppendChild(l, item)
+		parent.InsertAfter(parent, list, l)
+	}
+}
+
+// verbatim returns a paragraph containing the text written verbatim.
+func verbatim(s string) ast.Node {
+	p := ast.NewParagraph()
+	p.AppendChild(p, ast.NewString([]byte(s)))
+
+	return p
+}
+
+// unwrap replaces emphasis with its children surrounded by the marker.
+func unwrap(n *ast.Emphasis, marker string) {
+	parent := n.Parent()
+
+	parent.InsertBefore(parent, n, ast.NewString([]byte(marker)))
+
+	for c := n.FirstChild(); c != nil; {
+		next := c.NextSibling()
+		parent.InsertBefore(parent, n, c)
+		c = next
+	}
+
+	parent.ReplaceChild(parent, n, ast.NewString([]byte(marker)))
+}
+
+// intraword reports whether emphasis is next to a letter or digit.
+// Underscores do not emphasize parts of a word.
+func intraword(n ast.Node, source []byte) bool {
+	word := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+
+	switch prev := n.PreviousSibling().(type) {
+	case *ast.Text:
+		r, _ := utf8.DecodeLastRune(prev.Segment.Value(source))
+		if !prev.SoftLineBreak() && !prev.HardLineBreak() && word(r) {
+			return true
+		}
+	case *ast.String:
+		if r, _ := utf8.DecodeLastRune(prev.Value); word(r) {
+			return true
+		}
+	}
+
+	switch next := n.NextSibling().(type) {
+	case *ast.Text:
+		if r, _ := utf8.DecodeRune(next.Segment.Value(source)); word(r) {
+			return true
+		}
+	case *ast.String:
+		if r, _ := utf8.DecodeRune(next.Value); word(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// golang reports whether the code block is fenced with the go language.
+func golang(n ast.Node, source []byte) bool {
+	fc, ok := n.(*ast.FencedCodeBlock)
+	if !ok || fc.Info == nil {
+		return false
+	}
+
+	lang := string(fc.Language(source))
+
+	return lang == "go" || lang == "Go"
+}
+
+// fence returns a code block fenced with the character. If gofmt is
+// true, Go code is formatted like the markdown renderer does.
+func fence(n ast.Node, source []byte, c byte, gofmt bool) string {
+	var info []byte
+
+	if fc, ok := n.(*ast.FencedCodeBlock); ok && fc.Info != nil {
+		info = fc.Info.Text(source)
+	}
+
+	var code bytes.Buffer
+
+	for i := 0; i < n.Lines().Len(); i++ {
+		s := n.Lines().At(i)
+		_, _ = code.Write(s.Value(source))
+	}
+
+	if gofmt && golang(n, source) {
+		if b, err := goformat.Source(code.Bytes()); err == nil {
+			code.Reset()
+			_, _ = code.Write(b)
+		}
+	}
+
+	if code.Len() > 0 && !bytes.HasSuffix(code.Bytes(), []byte("\n")) {
+		_ = code.WriteByte('\n')
+	}
+
+	// The fence is longer than any fence within the code.
+	size := 3
+	for _, line := range strings.Split(code.String(), "\n") {
+		line = strings.TrimLeft(line, " ")
+		if k := len(line) - len(strings.TrimLeft(line, string(c))); k >= size {
+			size = k + 1
+		}
+	}
+
+	f := strings.Repeat(string(c), size)
+
+	return f + string(info) + "\n" + code.String() + f
+}
@@ -0,0 +1,141 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	gofmt "go/format"
+	stdhtml "html"
+	"reflect"
+	"regexp"
+
+	"github.com/bwplotka/mdox/pkg/gitdiff"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// VerifyError reports a formatted document which does not have the
+// meaning of the original document.
+type VerifyError struct {
+	// Name is the file name of the document or an empty string if the
+	// document was not read from a file.
+	Name string
+	// Diff contains the differences of the HTML renderings of the
+	// original and formatted documents.
+	Diff string
+}
+
+func (e *VerifyError) Error() string {
+	return "formatting changes the meaning of the document:\n" + e.Diff
+}
+
+// Verify checks the formatted document has the meaning of the original
+// document: both documents are rendered to HTML and compared, ignoring
+// whitespace. The front matter values are compared. If the documents
+// differ, Verify returns a *VerifyError.
+func (f *Formatter) Verify(md *Markdown, formatted []byte) error {
+	if bytes.Equal(md.source, formatted) {
+		return nil
+	}
+
+	out, err := Parse(bytes.NewReader(formatted))
+	if err != nil {
+		return fmt.Errorf("formatted document: %w", err)
+	}
+
+	if !reflect.DeepEqual(md.FrontMatter, out.FrontMatter) {
+		return &VerifyError{
+			Name: md.name,
+			Diff: "front matter differs",
+		}
+	}
+
+	a, err := f.render(md.Content)
+	if err != nil {
+		return err
+	}
+
+	b, err := f.render(out.Content)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(a, b) {
+		return nil
+	}
+
+	d := gitdiff.CompareBytes(
+		a, "original",
+		b, "formatted",
+	)
+
+	return &VerifyError{
+		Name: md.name,
+		Diff: string(d.ToCombinedFormat()),
+	}
+}
+
+// render returns the normalized HTML rendering of the markdown content.
+func (f *Formatter) render(content []byte) ([]byte, error) {
+	gm := goldmark.New(
+		goldmark.WithExtensions(f.profile.Extensions()...),
+		goldmark.WithParserOptions(f.profile.ParserOptions()...),
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+
+	var b bytes.Buffer
+
+	if err := gm.Convert(content, &b); err != nil {
+		return nil, err
+	}
+
+	return normalize(b.Bytes()), nil
+}
+
+var (
+	preBlock   = regexp.MustCompile(`(?s)<pre[ >].*?</pre>`)
+	goBlock    = regexp.MustCompile(`(?s)^(<pre><code class="language-[Gg]o">)(.*)(</code></pre>)$`)
+	whitespace = regexp.MustCompile(`\s+`)
+	tagSpace   = regexp.MustCompile(`\s*(<[^>]*>)\s*`)
+)
+
+// normalize removes the whitespace changed by formatting: whitespace is
+// collapsed and whitespace around tags is removed. Code blocks are kept
+// as is, except Go code which is formatted like WithGoFormat does. Each
+// tag starts a line.
+func normalize(b []byte) []byte {
+	var out bytes.Buffer
+
+	text := func(b []byte) {
+		b = whitespace.ReplaceAll(b, []byte(" "))
+		b = tagSpace.ReplaceAll(b, []byte("\n$1"))
+		_, _ = out.Write(b)
+	}
+
+	pos := 0
+
+	for _, m := range preBlock.FindAllIndex(b, -1) {
+		text(b[pos:m[0]])
+		_ = out.WriteByte('\n')
+		_, _ = out.Write(goCode(b[m[0]:m[1]]))
+		pos = m[1]
+	}
+
+	text(b[pos:])
+
+	return append(bytes.TrimSpace(out.Bytes()), '\n')
+}
+
+// goCode formats the code of a Go code block.
+func goCode(pre []byte) []byte {
+	m := goBlock.FindSubmatch(pre)
+	if m == nil {
+		return pre
+	}
+
+	code, err := gofmt.Source([]byte(stdhtml.UnescapeString(string(m[2]))))
+	if err != nil {
+		return pre
+	}
+
+	return []byte(string(m[1]) + stdhtml.EscapeString(string(code)) + string(m[3]))
+}
@@ -0,0 +1,340 @@
+package format
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// hardBreakTransformer replaces hard line breaks with a backslash
+// followed by a newline. The markdown renderer writes hard line breaks
+// as a newline which is joined to the next line when reflowing.
+type hardBreakTransformer struct{}
+
+func (t *hardBreakTransformer) Transform(doc *ast.Document, _ text.Reader, _ parser.Context) {
+	var breaks []*ast.Text
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if tn, ok := n.(*ast.Text); ok && entering && tn.HardLineBreak() {
+			breaks = append(breaks, tn)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, tn := range breaks {
+		tn.SetHardLineBreak(false)
+		tn.SetSoftLineBreak(false)
+		tn.Parent().InsertAfter(tn.Parent(), tn, ast.NewString([]byte("\\\n")))
+	}
+}
+
+// noBreakBefore matches words which would start a new block if placed
+// at the beginning of a line.
+var noBreakBefore = regexp.MustCompile(
+	"^(?:[-+*]|[0-9]{1,9}[.)]|#{1,6}|>.*|=+|-+|[*]{3,}|_{3,}|`{3}.*|~{3}.*|<.*)$",
+)
+
+// wrap reflows the paragraphs of a formatted document. Paragraphs are
+// expected to be on a single line, except for hard line breaks.
+func (f *Formatter) wrap(source []byte) []byte {
+	doc := f.parser().Parse(text.NewReader(source))
+
+	var out bytes.Buffer
+
+	pos := 0
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n.Kind() {
+		case ast.KindDocument, ast.KindList, ast.KindListItem, ast.KindBlockquote:
+			return ast.WalkContinue, nil
+		case ast.KindParagraph, ast.KindTextBlock:
+		default:
+			return ast.WalkSkipChildren, nil
+		}
+
+		lines := n.Lines()
+		if lines.Len() == 0 {
+			return ast.WalkSkipChildren, nil
+		}
+
+		first := lines.At(0)
+		bol := bytes.LastIndexByte(source[:first.Start], '\n') + 1
+		prefix := string(source[bol:first.Start])
+		end := eol(source, lines.At(lines.Len()-1).Start)
+
+		_, _ = out.Write(source[pos:bol])
+		_, _ = out.WriteString(f.reflow(source, lines, prefix))
+		pos = end
+
+		return ast.WalkSkipChildren, nil
+	})
+
+	_, _ = out.Write(source[pos:])
+
+	return out.Bytes()
+}
+
+// reflow wraps the lines of a paragraph. The first line starts with the
+// prefix. Continuation lines are indented to the same column, keeping
+// blockquote markers.
+func (f *Formatter) reflow(source []byte, lines *text.Segments, prefix string) string {
+	cont := []rune(prefix)
+	for i, c := range cont {
+		if c != '>' {
+			cont[i] = ' '
+		}
+	}
+
+	width := 0
+	if f.width > 0 {
+		width = max(f.width-runewidth.StringWidth(prefix), 1)
+	}
+
+	var wrapped []string
+
+	for i := 0; i < lines.Len(); i++ {
+		s := lines.At(i)
+		line := strings.TrimRight(string(source[s.Start:eol(source, s.Start)]), " \t")
+
+		hard := strings.HasSuffix(line, "\\")
+		if hard {
+			line = strings.TrimSuffix(line, "\\")
+		}
+
+		w := wrapWords(words(line), width, f.sentences)
+		if hard && len(w) > 0 {
+			w[len(w)-1] += "\\"
+		}
+
+		wrapped = append(wrapped, w...)
+	}
+
+	var b strings.Builder
+
+	for i, line := range wrapped {
+		if i == 0 {
+			b.WriteString(prefix)
+		} else {
+			b.WriteString("\n")
+			b.WriteString(string(cont))
+		}
+		b.WriteString(line)
+	}
+
+	return b.String()
+}
+
+// wrapWords joins the words into lines no longer than width, if
+// possible. If sentences is true, each sentence starts a new line.
+func wrapWords(words []string, width int, sentences bool) []string {
+	var lines []string
+	var cur strings.Builder
+	curw := 0
+
+	for i, word := range words {
+		ww := runewidth.StringWidth(word)
+
+		switch {
+		case cur.Len() == 0:
+		case width > 0 && curw+1+ww > width:
+			lines = append(lines, cur.String())
+			cur.Reset()
+			curw = 0
+		default:
+			cur.WriteString(" ")
+			curw++
+		}
+
+		cur.WriteString(word)
+		curw += ww
+
+		if sentences && i+1 < len(words) && sentenceEnd(word, words[i+1]) {
+			lines = append(lines, cur.String())
+			cur.Reset()
+			curw = 0
+		}
+	}
+
+	if cur.Len() > 0 {
+		lines = append(lines, cur.String())
+	}
+
+	return lines
+}
+
+// sentenceEnd reports whether a sentence ends between two words. A
+// sentence ends with a period, question or exclamation mark, optionally
+// followed by closing punctuation, and is followed by a word not
+// starting with a lower case letter.
+func sentenceEnd(word, next string) bool {
+	w := strings.TrimRight(word, `)]"'*_`)
+	if w == "" || !strings.ContainsRune(".!?", rune(w[len(w)-1])) {
+		return false
+	}
+
+	r, _ := utf8.DecodeRuneInString(next)
+
+	return !unicode.IsLower(r)
+}
+
+// words splits a line on spaces. Spaces in inline code, links, images,
+// autolinks and inline HTML are not split. Words which would start a
+// new block at the beginning of a line are joined to the previous word.
+func words(line string) []string {
+	var w []string
+
+	start := 0
+
+	for i := 0; i < len(line); {
+		switch c := line[i]; c {
+		case '\\':
+			i += 2
+			continue
+		case '`':
+			i = skipCode(line, i)
+			continue
+		case '<':
+			if i+1 < len(line) && strings.ContainsRune("/!?", rune(line[i+1])) || isLetter(line, i+1) {
+				if j := strings.IndexByte(line[i:], '>'); j > 0 {
+					i += j + 1
+					continue
+				}
+			}
+		case '!', '[':
+			if j := skipLink(line, i); j > i {
+				i = j
+				continue
+			}
+		case ' ':
+			if i > start {
+				w = append(w, line[start:i])
+			}
+			start = i + 1
+		}
+		i++
+	}
+
+	if start < len(line) {
+		w = append(w, line[start:])
+	}
+
+	joined := w[:0]
+
+	for _, word := range w {
+		if len(joined) > 0 && noBreakBefore.MatchString(word) {
+			joined[len(joined)-1] += " " + word
+			continue
+		}
+		joined = append(joined, word)
+	}
+
+	return joined
+}
+
+func isLetter(s string, i int) bool {
+	return i < len(s) && ('a' <= s[i] && s[i] <= 'z' || 'A' <= s[i] && s[i] <= 'Z')
+}
+
+// skipCode returns the index following a code span or the backticks if
+// the code span is not closed.
+func skipCode(s string, i int) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == '`' {
+		n++
+	}
+
+	fence := s[i : i+n]
+
+	for j := i + n; j < len(s); {
+		k := strings.Index(s[j:], fence)
+		if k < 0 {
+			break
+		}
+
+		j += k
+		if j+n < len(s) && s[j+n] == '`' {
+			for j < len(s) && s[j] == '`' {
+				j++
+			}
+			continue
+		}
+
+		return j + n
+	}
+
+	return i + n
+}
+
+// skipLink returns the index following a link or image starting at i,
+// or i if the bracket is not closed.
+func skipLink(s string, i int) int {
+	j := i
+	if s[j] == '!' {
+		if j+1 >= len(s) || s[j+1] != '[' {
+			return i
+		}
+		j++
+	}
+
+	end := closing(s, j, '[', ']')
+	if end < 0 {
+		return i
+	}
+
+	if end+1 < len(s) {
+		switch s[end+1] {
+		case '(':
+			if k := closing(s, end+1, '(', ')'); k > 0 {
+				return k + 1
+			}
+		case '[':
+			if k := closing(s, end+1, '[', ']'); k > 0 {
+				return k + 1
+			}
+		}
+	}
+
+	return end + 1
+}
+
+// closing returns the index of the bracket matching the bracket at i.
+func closing(s string, i int, open, close byte) int {
+	depth := 0
+
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '`':
+			j = skipCode(s, j) - 1
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+
+	return -1
+}
+
+// eol returns the index of the end of the line containing pos.
+func eol(source []byte, pos int) int {
+	if i := bytes.IndexByte(source[pos:], '\n'); i >= 0 {
+		return pos + i
+	}
+	return len(source)
+}
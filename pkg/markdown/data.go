@@ -0,0 +1,116 @@
+package markdown
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"go.abhg.dev/goldmark/toc"
+)
+
+// Heading is a heading of a document.
+type Heading struct {
+	Level int
+	Title string
+	ID    string
+}
+
+// dataKey holds the document data collected by the data transformer.
+var dataKey = parser.NewContextKey()
+
+// data is the document data collected before the table of contents is
+// inserted in the document.
+type data struct {
+	toc       *toc.TOC
+	headings  []Heading
+	wordCount int
+}
+
+// dataTransformer collects the headings, the table of contents and the
+// number of words of the document.
+type dataTransformer struct{}
+
+func (t *dataTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	d, ok := pc.Get(dataKey).(*data)
+	if !ok {
+		return
+	}
+
+	source := reader.Source()
+
+	d.toc, _ = toc.Inspect(doc, source)
+
+	// Words may span several text nodes: the text of the document is
+	// collected and blocks are separated by spaces.
+	var words bytes.Buffer
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if n.Type() == ast.TypeBlock {
+				_ = words.WriteByte(' ')
+			}
+			return ast.WalkContinue, nil
+		}
+
+		switch n := n.(type) {
+		case *ast.Heading:
+			h := Heading{
+				Level: n.Level,
+				Title: plainText(n, source),
+			}
+			if id, ok := n.AttributeString("id"); ok {
+				if b, ok := id.([]byte); ok {
+					h.ID = string(b)
+				}
+			}
+			d.headings = append(d.headings, h)
+		case *ast.Text:
+			_, _ = words.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				_ = words.WriteByte(' ')
+			}
+		case *ast.String:
+			_, _ = words.Write(n.Value)
+		case *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock:
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	d.wordCount = len(bytes.Fields(words.Bytes()))
+}
+
+// plainText returns the text of the inline nodes.
+func plainText(n ast.Node, source []byte) string {
+	var b strings.Builder
+
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch c := c.(type) {
+		case *ast.Text:
+			_, _ = b.Write(c.Segment.Value(source))
+			if c.SoftLineBreak() {
+				_ = b.WriteByte(' ')
+			}
+		case *ast.String:
+			_, _ = b.Write(c.Value)
+		case *ast.CodeSpan:
+			for t := c.FirstChild(); t != nil; t = t.NextSibling() {
+				if s, ok := t.(*ast.Text); ok {
+					_, _ = b.Write(s.Segment.Value(source))
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return b.String()
+}
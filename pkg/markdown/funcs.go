@@ -0,0 +1,358 @@
+package markdown
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// FuncMap maps names to template functions. See text/template.FuncMap.
+type FuncMap map[string]any
+
+// dateLayouts are the layouts of the dates parsed by the date functions.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// funcs returns the template functions for the document: the built-in
+// functions and the functions set with WithFuncs. If onRead is not nil,
+// it is called with the files read.
+func (o *Opt) funcs(doc string, onRead func(string)) FuncMap {
+	fm := FuncMap{
+		"absURL":      o.absURL,
+		"date":        formatDate,
+		"default":     defaultValue,
+		"dict":        dict,
+		"join":        join,
+		"markdownify": o.markdownify,
+		"now":         time.Now,
+		"parseDate":   ParseDate,
+		"readFile": func(name string) (string, error) {
+			return o.readFile(name, onRead)
+		},
+		"relURL": func(p string) (string, error) {
+			return o.relURL(doc, p)
+		},
+		"slug":     slug,
+		"truncate": truncate,
+	}
+
+	for k, v := range o.userFuncs {
+		fm[k] = v
+	}
+
+	return fm
+}
+
+// ParseDate returns the time of a date: a time, a TOML local date or
+// date-time, or a string in one of the date layouts. Local dates are in
+// UTC. Other values are parsed as strings if they implement
+// fmt.Stringer.
+func ParseDate(v any) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case toml.LocalDate:
+		return v.AsTime(time.UTC), nil
+	case toml.LocalDateTime:
+		return v.AsTime(time.UTC), nil
+	case fmt.Stringer:
+		return ParseDate(v.String())
+	case string:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+
+		return time.Time{}, fmt.Errorf("invalid date: %s", v)
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date: %v", v)
+}
+
+// formatDate formats a date with the layout: {{date "January 2, 2006"
+// .Date}}. An empty date is formatted as an empty string.
+func formatDate(layout string, v any) (string, error) {
+	if v == nil || v == "" {
+		return "", nil
+	}
+
+	t, err := ParseDate(v)
+	if err != nil {
+		return "", err
+	}
+
+	return t.Format(layout), nil
+}
+
+// defaultValue returns the value or, if the value is empty, the default:
+// {{.Params.lang | default "en"}}.
+func defaultValue(def, v any) any {
+	if v == nil {
+		return def
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if rv.Len() == 0 {
+			return def
+		}
+	case reflect.Bool:
+		if !rv.Bool() {
+			return def
+		}
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return def
+		}
+	}
+
+	return v
+}
+
+// dict returns a map of the key and value pairs: {{template "nav" dict
+// "page" . "class" "top"}}.
+func dict(kv ...any) (map[string]any, error) {
+	if len(kv)%2 != 0 {
+		return nil, errors.New("dict: odd number of arguments")
+	}
+
+	m := make(map[string]any, len(kv)/2)
+
+	for i := 0; i < len(kv); i += 2 {
+		k, ok := kv[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict: invalid key: %v", kv[i])
+		}
+
+		m[k] = kv[i+1]
+	}
+
+	return m, nil
+}
+
+// join joins the elements of a list, such as the tags of the front
+// matter: {{join ", " .Params.tags}}. A string is returned unchanged.
+func join(sep string, v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []string:
+		return strings.Join(v, sep), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: invalid list: %v", v)
+	}
+
+	s := make([]string, rv.Len())
+	for i := range s {
+		s[i] = fmt.Sprint(rv.Index(i).Interface())
+	}
+
+	return strings.Join(s, sep), nil
+}
+
+// truncate returns the first n characters of the string, followed by an
+// ellipsis if the string is longer. Words are not cut. If n is not
+// positive, truncate returns an empty string.
+func truncate(n int, s string) string {
+	if n <= 0 {
+		return ""
+	}
+
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+
+	cut := n
+	for cut > 0 && !unicode.IsSpace(r[cut]) {
+		cut--
+	}
+
+	if cut == 0 {
+		cut = n
+	}
+
+	return strings.TrimRightFunc(string(r[:cut]), unicode.IsSpace) + "…"
+}
+
+// slug returns the string in lower case, with words separated by
+// hyphens: "Hello, World!" -> "hello-world".
+func slug(s string) string {
+	var b strings.Builder
+
+	hyphen := false
+
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				_ = b.WriteByte('-')
+			}
+			_, _ = b.WriteRune(r)
+			hyphen = false
+			continue
+		}
+
+		hyphen = true
+	}
+
+	return b.String()
+}
+
+// markdownify converts inline markdown to HTML. A single paragraph is
+// not enclosed in a p element.
+func (o *Opt) markdownify(s string) (template.HTML, error) {
+	var b bytes.Buffer
+
+	if err := o.inline.Convert([]byte(s), &b); err != nil {
+		return "", err
+	}
+
+	out := bytes.TrimSpace(b.Bytes())
+
+	if p := bytes.TrimSuffix(bytes.TrimPrefix(out, []byte("<p>")), []byte("</p>")); len(p) == len(out)-7 && !bytes.Contains(p, []byte("<p>")) {
+		out = p
+	}
+
+	return template.HTML(out), nil
+}
+
+// relURL returns the path of a file of the project as a URL relative to
+// the HTML document: {{relURL "css/site.css"}}. URLs with a scheme are
+// returned unchanged.
+func (o *Opt) relURL(doc, p string) (string, error) {
+	u, err := url.Parse(p)
+	if err != nil {
+		return "", err
+	}
+
+	if u.IsAbs() || u.Host != "" {
+		return p, nil
+	}
+
+	dir := "."
+
+	if doc != "" {
+		rel, err := o.rel(doc)
+		if err != nil {
+			return "", err
+		}
+
+		dir = filepath.Dir(o.naming.Path(rel))
+	}
+
+	rel, err := filepath.Rel(dir, filepath.FromSlash(path.Join(".", u.Path)))
+	if err != nil {
+		return "", err
+	}
+
+	u.Path = filepath.ToSlash(rel)
+	if strings.HasSuffix(p, "/") && u.Path != "." {
+		u.Path += "/"
+	}
+
+	return u.String(), nil
+}
+
+// absURL returns the path of a file of the project as a URL relative to
+// the base URL: {{absURL "css/site.css"}}. Without base URL, the path is
+// absolute. URLs with a scheme are returned unchanged.
+func (o *Opt) absURL(p string) (string, error) {
+	u, err := url.Parse(p)
+	if err != nil {
+		return "", err
+	}
+
+	if u.IsAbs() || u.Host != "" {
+		return p, nil
+	}
+
+	base := o.baseURL
+	if base == "" {
+		base = "/"
+	}
+
+	return url.JoinPath(base, strings.TrimPrefix(p, "/"))
+}
+
+// readFile returns the content of a file of the project: {{readFile
+// "NOTICE.txt"}}. Files outside the project directory cannot be read.
+func (o *Opt) readFile(name string, onRead func(string)) (string, error) {
+	root, err := os.OpenRoot(o.root)
+	if err != nil {
+		return "", err
+	}
+
+	defer root.Close()
+
+	rel := filepath.FromSlash(path.Join(".", filepath.ToSlash(name)))
+
+	f, err := root.Open(rel)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+
+	if onRead != nil {
+		onRead(filepath.Join(o.root, rel))
+	}
+
+	return string(b), nil
+}
+
+// rel returns the path of a file relative to the project directory.
+func (o *Opt) rel(file string) (string, error) {
+	root, err := filepath.Abs(o.root)
+	if err != nil {
+		return "", err
+	}
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return "", err
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: outside of the project directory %s", file, o.root)
+	}
+
+	return rel, nil
+}
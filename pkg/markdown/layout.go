@@ -0,0 +1,214 @@
+package markdown
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template/parse"
+
+	"go.iscode.ca/mdg/pkg/format"
+)
+
+// LayoutFile is the template of the documents of a directory and its
+// subdirectories.
+const LayoutFile = "_layout.html"
+
+// PartialsDir is the directory of the partials in the layouts directory.
+const PartialsDir = "partials"
+
+// ErrNoLayouts is returned when the front matter of a document selects a
+// layout and no layouts directory is set.
+var ErrNoLayouts = errors.New("no layouts directory")
+
+// layouts finds the template of each document. The templates are parsed
+// once.
+type layouts struct {
+	dir string
+
+	mu        sync.Mutex
+	partials  map[string]*parse.Tree
+	templates map[string]*Template
+	def       *Template
+}
+
+func newLayouts() *layouts {
+	return &layouts{
+		templates: make(map[string]*Template),
+	}
+}
+
+// template returns the template of a document, in order:
+//
+//   - the layout named by the layout key of the front matter, which
+//     requires the layouts directory
+//   - the nearest _layout.html file of the directories of the document,
+//     up to the project directory
+//   - the default template
+//
+// The partials are added to the template.
+func (l *layouts) template(root, doc string, fm map[string]any, def *Template) (*Template, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	partials, err := l.loadPartials()
+	if err != nil {
+		return nil, err
+	}
+
+	if name := format.String("layout", fm); name != "" {
+		if l.dir == "" {
+			return nil, fmt.Errorf("layout %s: %w", name, ErrNoLayouts)
+		}
+
+		if !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("invalid layout: %s", name)
+		}
+
+		t, err := l.load(filepath.Join(l.dir, name+".html"), partials)
+		if err != nil {
+			return nil, fmt.Errorf("layout %s: %w", name, err)
+		}
+
+		return t, nil
+	}
+
+	if doc != "" {
+		for _, dir := range parents(root, filepath.Dir(doc)) {
+			t, err := l.load(filepath.Join(dir, LayoutFile), partials)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			return t, err
+		}
+	}
+
+	if l.def == nil {
+		l.def = def.with(partials)
+	}
+
+	return l.def, nil
+}
+
+// load returns the template of a file. Missing files are remembered.
+func (l *layouts) load(file string, partials map[string]*parse.Tree) (*Template, error) {
+	if t, ok := l.templates[file]; ok {
+		if t == nil {
+			return nil, fmt.Errorf("%s: %w", file, fs.ErrNotExist)
+		}
+		return t, nil
+	}
+
+	b, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		l.templates[file] = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := ParseTemplate(filepath.Base(file), string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	t = t.with(partials)
+	l.templates[file] = t
+
+	return t, nil
+}
+
+// loadPartials parses the partials of the layouts directory. The name of
+// a partial is its file name without extension: partials/header.html
+// defines the "header" template.
+func (l *layouts) loadPartials() (map[string]*parse.Tree, error) {
+	if l.partials != nil || l.dir == "" {
+		return l.partials, nil
+	}
+
+	partials := make(map[string]*parse.Tree)
+
+	files, err := filepath.Glob(filepath.Join(l.dir, PartialsDir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+
+		if err := parseTrees(name, string(b), partials); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	l.partials = partials
+
+	return partials, nil
+}
+
+// parents returns the directory and its parents, up to the root
+// directory. Only the directory is returned if it is not in the root
+// directory.
+func parents(root, dir string) []string {
+	dirs := []string{dir}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return dirs
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dirs
+	}
+
+	rel, err := filepath.Rel(absRoot, abs)
+	if err != nil || !filepath.IsLocal(rel) {
+		return dirs
+	}
+
+	for rel != "." {
+		rel = filepath.Dir(rel)
+		dir = filepath.Dir(dir)
+		dirs = append(dirs, dir)
+	}
+
+	return dirs
+}
+
+// LayoutFiles returns the files which may contain the template of the
+// documents of the directory: the layouts and partials of the layouts
+// directory and the _layout.html files of the directory and its parents,
+// up to the project directory.
+func LayoutFiles(layoutsDir, root, dir string) ([]string, error) {
+	var files []string
+
+	if layoutsDir != "" {
+		for _, pattern := range []string{"*.html", filepath.Join(PartialsDir, "*.html")} {
+			m, err := filepath.Glob(filepath.Join(layoutsDir, pattern))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, m...)
+		}
+	}
+
+	for _, d := range parents(root, dir) {
+		file := filepath.Join(d, LayoutFile)
+
+		if _, err := os.Stat(file); err == nil {
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
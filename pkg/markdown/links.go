@@ -0,0 +1,77 @@
+package markdown
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// documentKey holds the path of the markdown document being converted.
+var documentKey = parser.NewContextKey()
+
+// linkTransformer rewrites relative links to markdown documents to
+// point to the generated HTML documents.
+type linkTransformer struct {
+	naming Naming
+	exts   []string
+}
+
+func (t *linkTransformer) Transform(node *ast.Document, _ text.Reader, pc parser.Context) {
+	doc, _ := pc.Get(documentKey).(string)
+
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		if link, ok := n.(*ast.Link); ok {
+			if dest, ok := t.rewrite(doc, string(link.Destination)); ok {
+				link.Destination = []byte(dest)
+			}
+		}
+
+		return ast.WalkContinue, nil
+	})
+}
+
+// rewrite returns the HTML destination of a link to a markdown
+// document, a file with one of the markdown extensions. Absolute URLs
+// and links to other files are unchanged.
+func (t *linkTransformer) rewrite(doc, dest string) (string, bool) {
+	u, err := url.Parse(dest)
+	if err != nil || u.IsAbs() || u.Host != "" || u.Opaque != "" {
+		return "", false
+	}
+
+	if u.Path == "" || path.IsAbs(u.Path) {
+		return "", false
+	}
+
+	ext := path.Ext(u.Path)
+
+	if !slices.ContainsFunc(t.exts, func(v string) bool { return strings.EqualFold(ext, v) }) {
+		return "", false
+	}
+
+	// The link is relative to the markdown document: resolve it relative
+	// to the location of the HTML document.
+	dir := "."
+	if doc != "" {
+		dir = filepath.Dir(t.naming.Path(filepath.Base(doc)))
+	}
+
+	p, err := filepath.Rel(dir, t.naming.Path(filepath.FromSlash(u.Path)))
+	if err != nil {
+		return "", false
+	}
+
+	u.Path = filepath.ToSlash(p)
+
+	return u.String(), true
+}
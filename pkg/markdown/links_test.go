@@ -0,0 +1,73 @@
+package markdown_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.iscode.ca/mdg/pkg/markdown"
+)
+
+const mdLinks = `[install](other.md#install)
+[query](../doc/README.markdown?x=1)
+[absolute](https://example.com/other.md)
+[rooted](/other.md)
+[image](image.png)
+[fragment](#local)
+[mdown](notes.mdown)
+`
+
+func TestRewriteLinks(t *testing.T) {
+	for _, v := range []struct {
+		opt      []markdown.Option
+		expected []string
+	}{
+		{
+			nil,
+			[]string{
+				`href="other.html#install"`,
+				`href="../doc/README.html?x=1"`,
+				`href="https://example.com/other.md"`,
+				`href="/other.md"`,
+				`href="image.png"`,
+				`href="#local"`,
+				`href="notes.mdown"`,
+			},
+		},
+		{
+			[]markdown.Option{markdown.WithNaming(markdown.NamingIndex)},
+			[]string{
+				`href="other.html#install"`,
+				`href="../doc/index.html?x=1"`,
+			},
+		},
+		{
+			[]markdown.Option{markdown.WithExtensions("mdown", ".MD")},
+			[]string{
+				`href="other.html#install"`,
+				`href="../doc/README.markdown?x=1"`,
+				`href="notes.html"`,
+			},
+		},
+		{
+			[]markdown.Option{markdown.WithRewriteLinks(false)},
+			[]string{
+				`href="other.md#install"`,
+				`href="../doc/README.markdown?x=1"`,
+			},
+		},
+	} {
+		b := &bytes.Buffer{}
+
+		if err := markdown.New(v.opt...).Convert(bytes.NewBufferString(mdLinks), b); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		for _, link := range v.expected {
+			if !strings.Contains(b.String(), link) {
+				t.Errorf("link not found: %s", link)
+			}
+		}
+	}
+}
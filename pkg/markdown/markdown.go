@@ -3,55 +3,107 @@ package markdown
 import (
 	"bytes"
 	_ "embed"
+	"html/template"
 	"io"
-	"text/template"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
-	"go.iscode.ca/mdg/pkg/config"
-	"go.iscode.ca/mdg/pkg/format"
 	d2 "github.com/FurqanSoftware/goldmark-d2"
 	"github.com/yuin/goldmark"
 	highlighting "github.com/yuin/goldmark-highlighting/v2"
 	meta "github.com/yuin/goldmark-meta"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/util"
 	"go.abhg.dev/goldmark/anchor"
 	mermaid "go.abhg.dev/goldmark/mermaid"
 	"go.abhg.dev/goldmark/toc"
+	"go.iscode.ca/mdg/pkg/config"
+	"go.iscode.ca/mdg/pkg/format"
+	"go.iscode.ca/mdg/pkg/syntax"
 	"oss.terrastruct.com/d2/d2layouts/d2elklayout"
 	"oss.terrastruct.com/d2/d2themes/d2themescatalog"
 )
 
 //go:embed default_tmpl.html
 var defaultHTML string
-var templateHTML *template.Template
+var templateHTML *Template
 
 //go:embed default.css
 var defaultCSS string
 
 func init() {
-	t, err := template.New("html").Parse(defaultHTML)
+	t, err := ParseTemplate("html", defaultHTML)
 	if err != nil {
 		panic(err)
 	}
 	templateHTML = t
 }
 
+// Opt converts and formats markdown documents. Opt is safe for
+// concurrent use: the parser, renderers and extensions do not keep
+// state between documents.
 type Opt struct {
 	goldmark.Markdown
 	f        *format.Formatter
 	linewrap bool
 	css      string
-	t        *template.Template
+	t        *Template
+	links    bool
+	naming   Naming
+	exts     []string
+
+	inline    goldmark.Markdown
+	userFuncs FuncMap
+	root      string
+	baseURL   string
+	layouts   *layouts
+	unescaped bool
+	userCSS   string
+	extendCSS bool
+	styles    []string
+	scripts   []string
+
+	profile     syntax.Profile
+	typographer bool
+	formatOpts  []format.Option
+	verify      bool
 }
 
 type Option func(*Opt)
 
-// WithCSS sets the CSS for the markdown template.
+// WithCSS sets the CSS for the markdown template. The CSS replaces the
+// default CSS. See WithExtendCSS.
 func WithCSS(s string) Option {
 	return func(o *Opt) {
-		if s != "" {
-			o.css = s
-		}
+		o.userCSS = s
+	}
+}
+
+// WithExtendCSS enables or disables appending the CSS to the default CSS
+// instead of replacing it.
+func WithExtendCSS(t bool) Option {
+	return func(o *Opt) {
+		o.extendCSS = t
+	}
+}
+
+// WithStyles adds the URLs of style sheets linked by the template. The
+// styles key of the front matter adds style sheets to a document.
+func WithStyles(urls ...string) Option {
+	return func(o *Opt) {
+		o.styles = append(o.styles, urls...)
+	}
+}
+
+// WithScripts adds the URLs of scripts loaded by the template. The
+// scripts key of the front matter adds scripts to a document.
+func WithScripts(urls ...string) Option {
+	return func(o *Opt) {
+		o.scripts = append(o.scripts, urls...)
 	}
 }
 
@@ -62,8 +114,8 @@ func WithLineWrap(t bool) Option {
 	}
 }
 
-// WithTemplate sets the markdown template.
-func WithTemplate(t *template.Template) Option {
+// WithTemplate sets the markdown template. See ParseTemplate.
+func WithTemplate(t *Template) Option {
 	return func(o *Opt) {
 		if t != nil {
 			o.t = t
@@ -71,39 +123,226 @@ func WithTemplate(t *template.Template) Option {
 	}
 }
 
+// WithTextTemplate enables or disables executing the templates with
+// text/template instead of html/template: the template data is not
+// escaped. Templates written before escaping was introduced may depend
+// on unescaped data.
+func WithTextTemplate(t bool) Option {
+	return func(o *Opt) {
+		o.unescaped = t
+	}
+}
+
+// WithLayouts sets the layouts directory. The front matter layout key
+// selects the template of a document in the directory: "layout: post"
+// selects post.html. Without layouts directory, converting a document
+// with a layout key fails. Templates in the partials subdirectory are
+// available to all templates: partials/header.html defines the "header"
+// template.
+//
+// Without layout key, the template of a document is the nearest
+// _layout.html file of the directories of the document, up to the
+// project directory, or the template. See WithRoot.
+func WithLayouts(dir string) Option {
+	return func(o *Opt) {
+		o.layouts.dir = dir
+	}
+}
+
+// WithFuncs adds functions to the template functions. The functions
+// override the built-in functions of the same name.
+func WithFuncs(fm FuncMap) Option {
+	return func(o *Opt) {
+		if o.userFuncs == nil {
+			o.userFuncs = make(FuncMap, len(fm))
+		}
+
+		for k, v := range fm {
+			o.userFuncs[k] = v
+		}
+	}
+}
+
+// WithRoot sets the project directory: the template functions read files
+// and resolve paths relative to the directory (default the working
+// directory).
+func WithRoot(dir string) Option {
+	return func(o *Opt) {
+		if dir != "" {
+			o.root = dir
+		}
+	}
+}
+
+// WithBaseURL sets the URL of the project directory used by the absURL
+// template function.
+func WithBaseURL(u string) Option {
+	return func(o *Opt) {
+		o.baseURL = u
+	}
+}
+
+// WithRewriteLinks enables or disables rewriting relative links to
+// markdown documents to the generated HTML documents.
+func WithRewriteLinks(t bool) Option {
+	return func(o *Opt) {
+		o.links = t
+	}
+}
+
+// WithExtensions sets the file extensions of the markdown documents
+// whose links are rewritten. The default extensions are .md and
+// .markdown.
+func WithExtensions(exts ...string) Option {
+	return func(o *Opt) {
+		o.exts = nil
+		for _, v := range exts {
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			if !strings.HasPrefix(v, ".") {
+				v = "." + v
+			}
+			o.exts = append(o.exts, v)
+		}
+	}
+}
+
+// WithNaming sets the naming pattern used for generated HTML documents.
+func WithNaming(n Naming) Option {
+	return func(o *Opt) {
+		if n != "" {
+			o.naming = n
+		}
+	}
+}
+
+// WithProfile sets the markdown syntax used by the converter and the
+// formatter.
+func WithProfile(p syntax.Profile) Option {
+	return func(o *Opt) {
+		o.profile = p
+	}
+}
+
+// WithDefinitionList enables or disables definition lists.
+func WithDefinitionList(t bool) Option {
+	return func(o *Opt) {
+		o.profile.DefinitionList = t
+	}
+}
+
+// WithFootnote enables or disables footnotes.
+func WithFootnote(t bool) Option {
+	return func(o *Opt) {
+		o.profile.Footnote = t
+	}
+}
+
+// WithTypographer enables or disables replacing punctuation with
+// typographic entities such as smart quotes and dashes. Typography is
+// disabled by default.
+func WithTypographer(t bool) Option {
+	return func(o *Opt) {
+		o.typographer = t
+	}
+}
+
+// WithVerify enables or disables verifying formatted documents have the
+// meaning of the original documents. See format.Formatter.Verify.
+func WithVerify(t bool) Option {
+	return func(o *Opt) {
+		o.verify = t
+	}
+}
+
+// WithFormatOptions sets additional options for the formatter.
+func WithFormatOptions(opt ...format.Option) Option {
+	return func(o *Opt) {
+		o.formatOpts = append(o.formatOpts, opt...)
+	}
+}
+
 func New(opt ...Option) *Opt {
 	o := &Opt{
-		Markdown: goldmark.New(
-			goldmark.WithParserOptions(parser.WithAutoHeadingID()),
-			goldmark.WithExtensions(
-				extension.GFM,
-				meta.Meta,
-				&mermaid.Extender{
-					Theme: "neutral",
-				},
-				highlighting.Highlighting,
-				&toc.Extender{},
-				&anchor.Extender{},
-				&d2.Extender{
-					Layout:  d2elklayout.DefaultLayout,
-					ThemeID: &d2themescatalog.TerminalGrayscale.ID,
-				},
-			),
-		),
 		t:        templateHTML,
-		css:      defaultCSS,
 		linewrap: true,
+		links:    true,
+		naming:   NamingExt,
+		exts:     []string{".md", ".markdown"},
+		root:     ".",
+		layouts:  newLayouts(),
+
+		profile: syntax.New(),
 	}
 
 	for _, fn := range opt {
 		fn(o)
 	}
 
-	o.f = format.New(format.WithLineWrap(o.linewrap))
+	switch {
+	case o.userCSS == "":
+		o.css = defaultCSS
+	case o.extendCSS:
+		o.css = defaultCSS + "\n" + o.userCSS
+	default:
+		o.css = o.userCSS
+	}
+
+	parserOpts := append(o.profile.ParserOptions(),
+		parser.WithAutoHeadingID(),
+		// The document data is collected before the table of contents is
+		// inserted.
+		parser.WithASTTransformers(util.Prioritized(&dataTransformer{}, 50)),
+	)
+
+	if o.links {
+		parserOpts = append(parserOpts, parser.WithASTTransformers(
+			util.Prioritized(&linkTransformer{naming: o.naming, exts: o.exts}, 100),
+		))
+	}
+
+	extensions := o.profile.Extensions()
+
+	if o.typographer {
+		extensions = append(extensions, extension.Typographer)
+	}
+
+	o.Markdown = goldmark.New(
+		goldmark.WithParserOptions(parserOpts...),
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithExtensions(
+			meta.Meta,
+			&mermaid.Extender{
+				Theme: "neutral",
+			},
+			highlighting.Highlighting,
+			&toc.Extender{},
+			&anchor.Extender{},
+			&d2.Extender{
+				Layout:  d2elklayout.DefaultLayout,
+				ThemeID: &d2themescatalog.TerminalGrayscale.ID,
+			},
+		),
+	)
+
+	// Template functions convert markdown without the table of contents.
+	o.inline = goldmark.New(
+		goldmark.WithParserOptions(o.profile.ParserOptions()...),
+		goldmark.WithExtensions(extensions...),
+	)
+
+	o.f = format.New(append([]format.Option{
+		format.WithLineWrap(o.linewrap),
+		format.WithProfile(o.profile),
+	}, o.formatOpts...)...)
 
 	return o
 }
 
+// Metadata is the data of the HTML template. The body, the menu, the
+// table of contents, the head and the CSS are trusted: they are not
+// escaped.
 type Metadata struct {
 	Author     string
 	Title      string
@@ -113,8 +352,68 @@ type Metadata struct {
 	Date       string
 	Footer     map[string]string
 	Styles     []string
-	DefaultCSS string
-	Body       string
+	Scripts    []string
+	Head       template.HTML
+	DefaultCSS template.CSS
+	Body       template.HTML
+	Menu       template.HTML
+	Prev       *Link
+	Next       *Link
+
+	// Params is the front matter of the document.
+	Params map[string]any
+	// Path is the path of the markdown document or an empty string if
+	// the document was not read from a file.
+	Path string
+	// SourceModTime is the modification time of the markdown document.
+	// The time is zero if the document was not read from a file.
+	SourceModTime time.Time
+	// TOC is the table of contents of the document as an HTML list.
+	TOC template.HTML
+	// Headings are the headings of the document, in order.
+	Headings []Heading
+	// WordCount is the number of words of the document, excluding code
+	// blocks and HTML blocks.
+	WordCount int
+
+	// readFile is called with the files read by the template.
+	readFile func(name string)
+}
+
+// Link is a reference to another document.
+type Link struct {
+	Title string
+	URL   string
+}
+
+// ConvertOption sets per document template metadata.
+type ConvertOption func(*Metadata)
+
+// WithReadFile calls fn with the path of each file read by the readFile
+// template function.
+func WithReadFile(fn func(name string)) ConvertOption {
+	return func(m *Metadata) {
+		m.readFile = fn
+	}
+}
+
+// list returns the strings of a front matter list. A string is a list of
+// one string.
+func list(key string, fm map[string]any) []string {
+	switch v := fm[key].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		l := make([]string, 0, len(v))
+		for _, x := range v {
+			if s, ok := x.(string); ok {
+				l = append(l, s)
+			}
+		}
+		return l
+	}
+
+	return nil
 }
 
 func metadata(key string, fm map[string]any, def string) string {
@@ -126,7 +425,8 @@ func metadata(key string, fm map[string]any, def string) string {
 	return s
 }
 
-func (o *Opt) Convert(r io.Reader, w io.Writer) error {
+// Convert converts a markdown document to HTML using the template.
+func (o *Opt) Convert(r io.Reader, w io.Writer, opt ...ConvertOption) error {
 	md, err := format.Parse(r)
 	if err != nil {
 		return err
@@ -134,10 +434,37 @@ func (o *Opt) Convert(r io.Reader, w io.Writer) error {
 
 	var body bytes.Buffer
 
-	if err := o.Markdown.Convert(md.Content, &body); err != nil {
+	d := &data{}
+
+	pc := parser.NewContext()
+	pc.Set(documentKey, md.Name())
+	pc.Set(dataKey, d)
+
+	if err := o.Markdown.Convert(md.Content, &body, parser.WithContext(pc)); err != nil {
 		return err
 	}
 
+	var tocHTML bytes.Buffer
+
+	if list := toc.RenderList(d.toc); list != nil {
+		if err := o.Markdown.Renderer().Render(&tocHTML, md.Content, list); err != nil {
+			return err
+		}
+	}
+
+	var modTime time.Time
+
+	if md.Name() != "" {
+		if st, err := os.Stat(md.Name()); err == nil {
+			modTime = st.ModTime()
+		}
+	}
+
+	params := md.FrontMatter
+	if params == nil {
+		params = make(map[string]any)
+	}
+
 	metadata := &Metadata{
 		Author:     format.String("author", md.FrontMatter),
 		Title:      format.String("title", md.FrontMatter),
@@ -146,18 +473,55 @@ func (o *Opt) Convert(r io.Reader, w io.Writer) error {
 		VCS:        metadata("vcs", md.FrontMatter, config.Repo()),
 		Date:       format.String("date", md.FrontMatter),
 		Footer:     format.Map("footer", md.FrontMatter),
-		DefaultCSS: o.css,
-		Body:       body.String(),
+		Styles:     append(slices.Clip(o.styles), list("styles", md.FrontMatter)...),
+		Scripts:    append(slices.Clip(o.scripts), list("scripts", md.FrontMatter)...),
+		Head:       template.HTML(format.String("head", md.FrontMatter)),
+		DefaultCSS: template.CSS(o.css),
+		Body:       template.HTML(body.String()),
+
+		Params:        params,
+		Path:          filepath.ToSlash(md.Name()),
+		SourceModTime: modTime,
+		TOC:           template.HTML(tocHTML.String()),
+		Headings:      d.headings,
+		WordCount:     d.wordCount,
 	}
 
-	return o.t.Execute(w, metadata)
+	for _, fn := range opt {
+		fn(metadata)
+	}
+
+	t, err := o.layouts.template(o.root, md.Name(), md.FrontMatter, o.t)
+	if err != nil {
+		return err
+	}
+
+	return t.execute(w, metadata, o.funcs(md.Name(), metadata.readFile), o.unescaped)
 }
 
+// Format formats a markdown document. If verification is enabled,
+// nothing is written if formatting changes the meaning of the document.
 func (o *Opt) Format(r io.Reader, w io.Writer) error {
 	md, err := format.Parse(r)
 	if err != nil {
 		return err
 	}
 
-	return o.f.Format(w, md)
+	if !o.verify {
+		return o.f.Format(w, md)
+	}
+
+	var b bytes.Buffer
+
+	if err := o.f.Format(&b, md); err != nil {
+		return err
+	}
+
+	if err := o.f.Verify(md, b.Bytes()); err != nil {
+		return err
+	}
+
+	_, err = w.Write(b.Bytes())
+
+	return err
 }
@@ -0,0 +1,449 @@
+package markdown_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.iscode.ca/mdg/pkg/markdown"
+)
+
+const mdConcurrent = `# Title
+
+## Section
+
+Text with [a link](other.md) and ` + "`code`" + `.
+
+` + "```go" + `
+package main
+` + "```" + `
+
+` + "```d2" + `
+a -> b
+` + "```" + `
+
+` + "```mermaid" + `
+graph TD;
+  A-->B;
+` + "```" + `
+`
+
+// TestConvertConcurrent converts documents with diagrams using the same
+// options. Run with -race.
+func TestConvertConcurrent(t *testing.T) {
+	md := markdown.New()
+
+	var expected bytes.Buffer
+
+	if err := md.Convert(strings.NewReader(mdConcurrent), &expected); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+
+	for range 4 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			var b bytes.Buffer
+
+			if err := md.Convert(strings.NewReader(mdConcurrent), &b); err != nil {
+				t.Errorf("%v", err)
+				return
+			}
+
+			if b.String() != expected.String() {
+				t.Errorf("expected:\n%s\ngot:\n%s", expected.String(), b.String())
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+const mdData = `---
+title: Data
+description: Template data
+---
+# Title
+
+Some words in a *paragraph*.
+
+## Section
+
+` + "```" + `
+not counted
+` + "```" + `
+`
+
+func TestConvertData(t *testing.T) {
+	tmpl, err := markdown.ParseTemplate("html",
+		`{{.Params.description}}|{{.WordCount}}|{{range .Headings}}{{.Level}}:{{.ID}}:{{.Title}};{{end}}|{{.TOC}}`,
+	)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	md := markdown.New(markdown.WithTemplate(tmpl))
+
+	var b bytes.Buffer
+
+	if err := md.Convert(strings.NewReader(mdData), &b); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	expected := `Template data|7|1:title:Title;2:section:Section;|`
+	if !strings.HasPrefix(b.String(), expected) {
+		t.Errorf("expected prefix:\n%s\ngot:\n%s", expected, b.String())
+		return
+	}
+
+	if !strings.Contains(b.String(), `<a href="#section">Section</a>`) {
+		t.Errorf("expected table of contents, got:\n%s", b.String())
+	}
+}
+
+const mdEscape = `---
+title: "<script>alert(1)</script>"
+footer:
+  home: "javascript:alert(1)"
+---
+# Title
+`
+
+func TestConvertEscape(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := markdown.New().Convert(strings.NewReader(mdEscape), &b); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	for _, s := range []string{"<script>", "javascript:"} {
+		if strings.Contains(b.String(), s) {
+			t.Errorf("unescaped %q:\n%s", s, b.String())
+		}
+	}
+
+	if !strings.Contains(b.String(), `<h1 id="title">`) {
+		t.Errorf("escaped body:\n%s", b.String())
+	}
+
+	tmpl, err := markdown.ParseTemplate("html", "<title>{{.Title}}</title>")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	b.Reset()
+
+	md := markdown.New(
+		markdown.WithTemplate(tmpl),
+		markdown.WithTextTemplate(true),
+	)
+
+	if err := md.Convert(strings.NewReader(mdEscape), &b); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if expected := "<title><script>alert(1)</script></title>"; b.String() != expected {
+		t.Errorf("expected %s, got %s", expected, b.String())
+	}
+}
+
+const tmplFuncs = `{{date "Jan 2, 2006" .Params.date}}
+{{.Params.lang | default "en"}}
+{{slug .Title}}
+{{join ", " .Params.tags}}
+{{truncate 10 .Params.description}}
+{{markdownify .Params.summary}}
+{{relURL "css/site.css"}}
+{{absURL "css/site.css"}}
+{{readFile "NOTICE"}}
+{{with dict "name" "value"}}{{.name}}{{end}}
+{{shout "custom"}}`
+
+const mdFuncs = `---
+title: Hello, World!
+date: 2024-03-05
+tags: [go, markdown]
+description: A long description of the document
+summary: Some *emphasis*
+---
+# Title
+`
+
+const mdFuncsTOML = `+++
+date = 2024-03-05
+updated = 2024-03-05T10:30:00
++++
+# Title
+`
+
+func TestConvertFuncs(t *testing.T) {
+	root := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(root, "NOTICE"), []byte("notice"), 0o644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := os.Mkdir(filepath.Join(root, "docs"), 0o755); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	doc := filepath.Join(root, "docs", "page.md")
+
+	if err := os.WriteFile(doc, []byte(mdFuncs), 0o644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	tmpl, err := markdown.ParseTemplate("html", tmplFuncs)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	md := markdown.New(
+		markdown.WithTemplate(tmpl),
+		markdown.WithNaming(markdown.NamingPretty),
+		markdown.WithRoot(root),
+		markdown.WithBaseURL("https://example.com/site/"),
+		markdown.WithFuncs(markdown.FuncMap{
+			"shout": strings.ToUpper,
+		}),
+	)
+
+	f, err := os.Open(doc)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	defer f.Close()
+
+	var b bytes.Buffer
+
+	var read []string
+
+	if err := md.Convert(f, &b, markdown.WithReadFile(func(name string) {
+		read = append(read, name)
+	})); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if expected := filepath.Join(root, "NOTICE"); len(read) != 1 || read[0] != expected {
+		t.Errorf("expected read files [%s], got %v", expected, read)
+	}
+
+	expected := `Mar 5, 2024
+en
+hello-world
+go, markdown
+A long…
+Some <em>emphasis</em>
+../../css/site.css
+https://example.com/site/css/site.css
+notice
+value
+CUSTOM`
+
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	// TOML dates are not strings.
+	tmpl, err = markdown.ParseTemplate("html", `{{date "Jan 2, 2006" .Params.date}}|{{date "15:04" .Params.updated}}`)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	b.Reset()
+
+	md = markdown.New(markdown.WithTemplate(tmpl))
+
+	if err := md.Convert(strings.NewReader(mdFuncsTOML), &b); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if expected := "Mar 5, 2024|10:30"; b.String() != expected {
+		t.Errorf("expected %s, got %s", expected, b.String())
+	}
+
+	tmpl, err = markdown.ParseTemplate("html", `{{truncate -1 "abc"}}|{{truncate 0 "abc"}}|{{truncate 2 "abc"}}|{{truncate 3 "ab cd"}}|{{truncate 5 "abc"}}`)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	b.Reset()
+
+	md = markdown.New(markdown.WithTemplate(tmpl))
+
+	if err := md.Convert(strings.NewReader(mdFuncs), &b); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if expected := "||ab…|ab…|abc"; b.String() != expected {
+		t.Errorf("expected %s, got %s", expected, b.String())
+	}
+
+	tmpl, err = markdown.ParseTemplate("html", `{{readFile "../secret"}}`)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	md = markdown.New(markdown.WithTemplate(tmpl), markdown.WithRoot(root))
+
+	if err := md.Convert(strings.NewReader(mdFuncs), &b); err == nil {
+		t.Errorf("expected error reading a file outside of the project")
+	}
+}
+
+func TestConvertLayouts(t *testing.T) {
+	root := t.TempDir()
+
+	files := map[string]string{
+		"layouts/post.html":            `post {{template "header" .}}`,
+		"layouts/partials/header.html": `<h1>{{.Title}}</h1>`,
+		"_layout.html":                 `root {{template "header" .}}`,
+		"blog/_layout.html":            `blog {{.Title}}`,
+		"a.md":                         "---\ntitle: A\n---\n",
+		"blog/b.md":                    "---\ntitle: B\n---\n",
+		"blog/c.md":                    "---\ntitle: C\nlayout: post\n---\n",
+		"blog/deep/d.md":               "---\ntitle: D\n---\n",
+		"blog/e.md":                    "---\ntitle: E\nlayout: missing\n---\n",
+	}
+
+	for name, content := range files {
+		file := filepath.Join(root, name)
+
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+
+	md := markdown.New(
+		markdown.WithRoot(root),
+		markdown.WithLayouts(filepath.Join(root, "layouts")),
+	)
+
+	for _, v := range []struct {
+		doc      string
+		expected string
+	}{
+		{"a.md", "root <h1>A</h1>"},
+		{"blog/b.md", "blog B"},
+		{"blog/c.md", "post <h1>C</h1>"},
+		{"blog/deep/d.md", "blog D"},
+		{"blog/e.md", ""},
+	} {
+		f, err := os.Open(filepath.Join(root, v.doc))
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		var b bytes.Buffer
+
+		err = md.Convert(f, &b)
+		_ = f.Close()
+
+		switch {
+		case v.expected == "" && err == nil:
+			t.Errorf("%s: expected error", v.doc)
+		case v.expected != "" && err != nil:
+			t.Errorf("%s: %v", v.doc, err)
+		case b.String() != v.expected:
+			t.Errorf("%s: expected %q, got %q", v.doc, v.expected, b.String())
+		}
+	}
+
+	// A layout is not ignored without layouts directory.
+	f, err := os.Open(filepath.Join(root, "blog", "c.md"))
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	defer f.Close()
+
+	var b bytes.Buffer
+
+	if err := markdown.New(markdown.WithRoot(root)).Convert(f, &b); !errors.Is(err, markdown.ErrNoLayouts) {
+		t.Errorf("blog/c.md: expected %v, got %v", markdown.ErrNoLayouts, err)
+	}
+}
+
+const mdStyles = `---
+styles: [page.css]
+scripts: page.js
+head: <meta name="description" content="page">
+---
+# Title
+`
+
+func TestConvertStyles(t *testing.T) {
+	md := markdown.New(
+		markdown.WithCSS(".extra {}"),
+		markdown.WithExtendCSS(true),
+		markdown.WithStyles("site.css"),
+		markdown.WithScripts("site.js"),
+	)
+
+	var b bytes.Buffer
+
+	if err := md.Convert(strings.NewReader(mdStyles), &b); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	for _, s := range []string{
+		`<link rel="stylesheet" href="site.css" />`,
+		`<link rel="stylesheet" href="page.css" />`,
+		`<script src="site.js"></script>`,
+		`<script src="page.js"></script>`,
+		`<meta name="description" content="page">`,
+		`.extra {}`,
+		`.topbar`,
+	} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("expected %s in:\n%s", s, b.String())
+			return
+		}
+	}
+
+	b.Reset()
+
+	if err := markdown.New(markdown.WithCSS(".extra {}")).Convert(strings.NewReader(mdStyles), &b); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if strings.Contains(b.String(), ".topbar") {
+		t.Errorf("expected the CSS to replace the default CSS:\n%s", b.String())
+	}
+}
@@ -0,0 +1,53 @@
+package markdown
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Naming maps the path of a markdown document to the path of the
+// generated HTML document.
+type Naming string
+
+const (
+	// NamingExt replaces the markdown extension: foo.md -> foo.html
+	NamingExt Naming = "ext"
+	// NamingIndex converts README and index documents to index.html:
+	// README.md -> index.html, foo.md -> foo.html
+	NamingIndex Naming = "index"
+	// NamingPretty generates a directory per document:
+	// README.md -> index.html, foo.md -> foo/index.html
+	NamingPretty Naming = "pretty"
+)
+
+// ParseNaming returns the naming pattern for a string.
+func ParseNaming(s string) (Naming, error) {
+	switch n := Naming(s); n {
+	case "":
+		return NamingExt, nil
+	case NamingExt, NamingIndex, NamingPretty:
+		return n, nil
+	}
+
+	return "", fmt.Errorf("invalid naming: %s", s)
+}
+
+// Path returns the HTML path for a markdown document.
+func (n Naming) Path(file string) string {
+	dir, base := filepath.Split(file)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+
+	switch n {
+	case NamingIndex, NamingPretty:
+		if strings.EqualFold(name, "readme") || name == "index" {
+			return filepath.Join(dir, "index.html")
+		}
+
+		if n == NamingPretty {
+			return filepath.Join(dir, name, "index.html")
+		}
+	}
+
+	return filepath.Join(dir, name+".html")
+}
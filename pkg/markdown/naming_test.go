@@ -0,0 +1,27 @@
+package markdown_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.iscode.ca/mdg/pkg/markdown"
+)
+
+func TestNamingPath(t *testing.T) {
+	for _, v := range []struct {
+		naming markdown.Naming
+		file   string
+		html   string
+	}{
+		{markdown.NamingExt, "doc/README.md", "doc/README.html"},
+		{markdown.NamingExt, "foo.markdown", "foo.html"},
+		{markdown.NamingIndex, "doc/README.md", "doc/index.html"},
+		{markdown.NamingIndex, "doc/foo.md", "doc/foo.html"},
+		{markdown.NamingPretty, "doc/index.md", "doc/index.html"},
+		{markdown.NamingPretty, "doc/foo.md", "doc/foo/index.html"},
+	} {
+		if html := v.naming.Path(filepath.FromSlash(v.file)); html != filepath.FromSlash(v.html) {
+			t.Errorf("%s: %s: expected %s, got %s", v.naming, v.file, v.html, html)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package markdown
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// Template is an HTML template executed with the Metadata of a
+// document.
+type Template struct {
+	name  string
+	trees map[string]*parse.Tree
+}
+
+// ParseTemplate parses an HTML template. The template is executed with
+// html/template: the metadata is escaped, except the body, the menu, the
+// table of contents, the head and the CSS. html/template removes the
+// HTML comments of the template. See WithTextTemplate.
+//
+// The template may call the built-in functions and the functions set
+// with WithFuncs: functions are checked when the template is executed.
+func ParseTemplate(name, text string) (*Template, error) {
+	t := &Template{
+		name:  name,
+		trees: make(map[string]*parse.Tree),
+	}
+
+	if err := parseTrees(name, text, t.trees); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// parseTrees adds the templates defined by the text to the trees.
+func parseTrees(name, text string, trees map[string]*parse.Tree) error {
+	tree := parse.New(name)
+	tree.Mode = parse.SkipFuncCheck
+
+	_, err := tree.Parse(text, "", "", trees)
+
+	return err
+}
+
+// with returns a template containing the partials. The templates of t
+// replace the partials of the same name.
+func (t *Template) with(partials map[string]*parse.Tree) *Template {
+	if len(partials) == 0 {
+		return t
+	}
+
+	out := &Template{
+		name:  t.name,
+		trees: make(map[string]*parse.Tree, len(partials)+len(t.trees)),
+	}
+
+	for name, tree := range partials {
+		out.trees[name] = tree
+	}
+
+	for name, tree := range t.trees {
+		out.trees[name] = tree
+	}
+
+	return out
+}
+
+// execute applies the template to the data. The functions are added to
+// a copy of the template. If unescaped is true, the template is
+// executed with text/template.
+func (t *Template) execute(w io.Writer, data any, funcs FuncMap, unescaped bool) error {
+	if unescaped {
+		tt := texttemplate.New(t.name).Funcs(texttemplate.FuncMap(funcs))
+
+		for name, tree := range t.trees {
+			if _, err := tt.AddParseTree(name, tree); err != nil {
+				return err
+			}
+		}
+
+		return tt.Execute(w, data)
+	}
+
+	ht := template.New(t.name).Funcs(template.FuncMap(funcs))
+
+	// Escaping modifies the parse trees.
+	for name, tree := range t.trees {
+		if _, err := ht.AddParseTree(name, tree.Copy()); err != nil {
+			return err
+		}
+	}
+
+	if ht = ht.Lookup(t.name); ht == nil {
+		return fmt.Errorf("template: %q is an incomplete or empty template", t.name)
+	}
+
+	return ht.Execute(w, data)
+}
@@ -0,0 +1,103 @@
+// Package syntax configures the markdown syntax accepted by the
+// formatter and the HTML converter.
+package syntax
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+// Profile is the set of markdown extensions enabled when parsing a
+// document. Syntax accepted by the formatter is rendered the same way
+// by the converter.
+type Profile struct {
+	// GFM enables GitHub Flavored Markdown: tables, strikethrough,
+	// autolinks and task lists.
+	GFM bool
+	// Attribute enables attributes for headings and blocks:
+	//
+	//	# Heading {#custom-id .class}
+	Attribute bool
+	// DefinitionList enables PHP Markdown Extra definition lists.
+	DefinitionList bool
+	// Footnote enables PHP Markdown Extra footnotes.
+	Footnote bool
+}
+
+type Option func(*Profile)
+
+// WithGFM enables or disables GitHub Flavored Markdown.
+func WithGFM(t bool) Option {
+	return func(p *Profile) {
+		p.GFM = t
+	}
+}
+
+// WithAttribute enables or disables heading and block attributes.
+func WithAttribute(t bool) Option {
+	return func(p *Profile) {
+		p.Attribute = t
+	}
+}
+
+// WithDefinitionList enables or disables definition lists.
+func WithDefinitionList(t bool) Option {
+	return func(p *Profile) {
+		p.DefinitionList = t
+	}
+}
+
+// WithFootnote enables or disables footnotes.
+func WithFootnote(t bool) Option {
+	return func(p *Profile) {
+		p.Footnote = t
+	}
+}
+
+// New returns a profile with all extensions enabled unless disabled by
+// an option.
+func New(opt ...Option) Profile {
+	p := Profile{
+		GFM:            true,
+		Attribute:      true,
+		DefinitionList: true,
+		Footnote:       true,
+	}
+
+	for _, fn := range opt {
+		fn(&p)
+	}
+
+	return p
+}
+
+// Extensions returns the goldmark extensions for the profile.
+func (p Profile) Extensions() []goldmark.Extender {
+	var e []goldmark.Extender
+
+	if p.GFM {
+		e = append(e, extension.GFM)
+	}
+
+	if p.DefinitionList {
+		e = append(e, extension.DefinitionList)
+	}
+
+	if p.Footnote {
+		e = append(e, extension.Footnote)
+	}
+
+	return e
+}
+
+// ParserOptions returns the goldmark parser options for the profile.
+func (p Profile) ParserOptions() []parser.Option {
+	var opts []parser.Option
+
+	if p.Attribute {
+		opts = append(opts, parser.WithAttribute(), parser.WithHeadingAttribute())
+	}
+
+	return opts
+}
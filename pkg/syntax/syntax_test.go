@@ -0,0 +1,53 @@
+package syntax_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.iscode.ca/mdg/pkg/format"
+	"go.iscode.ca/mdg/pkg/markdown"
+	"go.iscode.ca/mdg/pkg/syntax"
+)
+
+const mdAttribute = `# Heading {#custom-id}
+`
+
+func TestProfileAttribute(t *testing.T) {
+	for _, v := range []struct {
+		p         syntax.Profile
+		formatted string
+		html      string
+	}{
+		{syntax.New(), mdAttribute, `<h1 id="custom-id">`},
+		{syntax.New(syntax.WithAttribute(false)), `# Heading {#custom-id}` + "\n", `<h1 id="heading-custom-id">`},
+	} {
+		md, err := format.Parse(bytes.NewBufferString(mdAttribute))
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		b := &bytes.Buffer{}
+
+		if err := format.New(format.WithProfile(v.p)).Format(b, md); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		if b.String() != v.formatted {
+			t.Errorf("format: expected %q, got %q", v.formatted, b.String())
+		}
+
+		b.Reset()
+
+		if err := markdown.New(markdown.WithProfile(v.p)).Convert(bytes.NewBufferString(mdAttribute), b); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		if !strings.Contains(b.String(), v.html) {
+			t.Errorf("convert: %s: not found", v.html)
+		}
+	}
+}